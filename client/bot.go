@@ -0,0 +1,194 @@
+package client
+
+import (
+	"dunExpo/dungeon"
+	"dunExpo/game"
+	"math/rand"
+)
+
+// View is what a strategy decides from: the bot's current view of the game
+// and its own player, plus a random source for tie-breaking.
+type View struct {
+	State *game.GameStateForJSON
+	Me    *game.Player
+	Rand  *rand.Rand
+}
+
+// Strategy picks a bot's next command. It is only asked while the bot's
+// player is alive and its view is current.
+type Strategy interface {
+	Name() string
+	Decide(v View) game.Command
+}
+
+// Strategies returns one of each built-in strategy.
+func Strategies() []Strategy {
+	return []Strategy{RandomWalker{}, Explorer{}, ExitSeeker{}, Fighter{}}
+}
+
+// StrategyByName looks up a built-in strategy.
+func StrategyByName(name string) (Strategy, bool) {
+	for _, s := range Strategies() {
+		if s.Name() == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+func move(dir string) game.Command {
+	return game.Command{Type: game.CmdMove, Dir: dir}
+}
+
+func randomMove(v View) game.Command {
+	return move(steps[v.Rand.Intn(len(steps))].dir)
+}
+
+// RandomWalker stumbles about at random.
+type RandomWalker struct{}
+
+func (RandomWalker) Name() string { return "random" }
+
+func (RandomWalker) Decide(v View) game.Command {
+	return randomMove(v)
+}
+
+// Explorer heads for the nearest edge of the map it has not seen yet.
+type Explorer struct{}
+
+func (Explorer) Name() string { return "explorer" }
+
+func (Explorer) Decide(v View) game.Command {
+	return explore(v)
+}
+
+func explore(v View) game.Command {
+	dir, ok := route(v.State, v.Me.ID, v.Me.Position, func(p dungeon.Point) bool {
+		return frontier(v.State, p)
+	})
+	if !ok {
+		return randomMove(v)
+	}
+	return move(dir)
+}
+
+// ExitSeeker explores until it has seen the exit, then makes for it.
+type ExitSeeker struct{}
+
+func (ExitSeeker) Name() string { return "exit" }
+
+func (ExitSeeker) Decide(v View) game.Command {
+	if v.State.ExitPos != nil {
+		exit := *v.State.ExitPos
+		if dir, ok := route(v.State, v.Me.ID, v.Me.Position, func(p dungeon.Point) bool { return p == exit }); ok {
+			return move(dir)
+		}
+	}
+	return explore(v)
+}
+
+// Fighter hunts visible monsters, picks up items, shoots when it has a
+// ranged weapon, uses its class ability when it has an obvious use and drinks
+// a potion or heads for a fountain when badly hurt.
+type Fighter struct{}
+
+func (Fighter) Name() string { return "fighter" }
+
+func (Fighter) Decide(v View) game.Command {
+	me := v.Me
+	if cmd, ok := ability(v); ok {
+		return cmd
+	}
+	for _, m := range v.State.Monsters {
+		if game.Distance(me.Position, m.Position) == 1 {
+			target := m.Position
+			return game.Command{Type: game.CmdAttack, Target: &target}
+		}
+	}
+	for _, it := range v.State.ItemsOnGround {
+		if it.Position == me.Position {
+			return game.Command{Type: game.CmdPickup}
+		}
+	}
+	if me.HP*3 < me.MaxHP {
+		for _, item := range me.Inventory {
+			if item.IsConsumable && item.Effect == game.EffectHeal {
+				return game.Command{Type: game.CmdUse, Item: item.Name}
+			}
+		}
+		if dir, ok := route(v.State, me.ID, me.Position, func(p dungeon.Point) bool {
+			return tileAt(v.State, p) == dungeon.TileHealth
+		}); ok {
+			return move(dir)
+		}
+	}
+	if me.EquippedWeapon != nil && me.EquippedWeapon.Range > 1 {
+		for _, m := range v.State.Monsters {
+			if game.Distance(me.Position, m.Position) <= me.EquippedWeapon.Range {
+				target := m.Position
+				return game.Command{Type: game.CmdAttack, Target: &target}
+			}
+		}
+	}
+	if dir, ok := route(v.State, me.ID, me.Position, func(p dungeon.Point) bool {
+		for _, m := range v.State.Monsters {
+			if m.Position == p {
+				return true
+			}
+		}
+		for _, it := range v.State.ItemsOnGround {
+			if it.Position == p {
+				return true
+			}
+		}
+		return false
+	}); ok {
+		return move(dir)
+	}
+	return explore(v)
+}
+
+// ability returns the class ability command when it is ready and worth
+// using: a bash with a monster next to us, a volley at two or more monsters
+// in range, a look around when nothing is in sight, or a heal for an ally
+// below half HP.
+func ability(v View) (game.Command, bool) {
+	me := v.Me
+	class, ok := game.Classes[me.Class]
+	if !ok || me.AbilityCooldown > 0 {
+		return game.Command{}, false
+	}
+	cmd := game.Command{Type: game.CmdAbility}
+	switch class.Ability {
+	case game.AbilityShieldBash:
+		for _, m := range v.State.Monsters {
+			if game.Distance(me.Position, m.Position) == 1 {
+				target := m.Position
+				cmd.Target = &target
+				return cmd, true
+			}
+		}
+	case game.AbilityMultiShot:
+		if me.EquippedWeapon == nil || me.EquippedWeapon.Range <= 1 {
+			break
+		}
+		inRange := 0
+		for _, m := range v.State.Monsters {
+			if game.Distance(me.Position, m.Position) <= me.EquippedWeapon.Range {
+				inRange++
+			}
+		}
+		return cmd, inRange >= 2
+	case game.AbilityExtendedVision:
+		return cmd, me.VisionBonus == 0 && len(v.State.Monsters) == 0
+	case game.AbilityHealAlly:
+		for id, ally := range v.State.Players {
+			if id != me.ID && ally.Status != "defeated" && ally.HP*2 < ally.MaxHP && game.Distance(me.Position, ally.Position) <= 3 {
+				target := ally.Position
+				cmd.Target = &target
+				return cmd, true
+			}
+		}
+	}
+	return game.Command{}, false
+}
@@ -0,0 +1,244 @@
+// Package client speaks the server's /ws protocol: the lobby handshake, typed
+// commands, and state keyframes and deltas, which it applies to a local copy
+// of the player's view. It drives the bots in this package and can drive any
+// other Go client.
+package client
+
+import (
+	"context"
+	"dunExpo/game"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// InitialMessage is the first message a client sends: "create", "join" or
+// "resume". It mirrors the server's lobby request.
+type InitialMessage struct {
+	Type      string `json:"type"`
+	Code      string `json:"code"`
+	Seed      int64  `json:"seed,omitempty"`
+	Depth     int    `json:"depth,omitempty"`
+	Generator string `json:"generator,omitempty"`
+	SharedMap bool   `json:"sharedMap,omitempty"`
+	SharedXP  bool   `json:"sharedXP,omitempty"`
+	Token     string `json:"token,omitempty"`
+	Class     string `json:"class,omitempty"`
+}
+
+// ServerResponse is any message from the server that is not a state update:
+// "welcome", "error", "gameOver" and "shutdown".
+type ServerResponse struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+	ID      string `json:"id,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Result  string `json:"result,omitempty"`
+	Seed    int64  `json:"seed,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+// ServerError is an "error" reply from the server.
+type ServerError struct {
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return "server error: " + e.Message
+}
+
+// Room describes the room to create; zero values take the server defaults.
+// Class is the class the creator plays.
+type Room struct {
+	Code      string
+	Seed      int64
+	Depth     int
+	Generator string
+	SharedMap bool
+	SharedXP  bool
+	Class     string
+}
+
+// Client is one connection to the server. One goroutine may run Next while
+// another sends commands and reads the state through Inspect; State and the
+// counters must not be touched directly while Next is running.
+type Client struct {
+	conn *websocket.Conn
+	// mu guards State and the fields after it while Next applies updates.
+	mu sync.Mutex
+	// sendMu serialises writes.
+	sendMu sync.Mutex
+	// ID, Code, Token and Seed come from the welcome message.
+	ID    string
+	Code  string
+	Token string
+	Seed  int64
+	// State is this player's view as of the last update.
+	State game.GameStateForJSON
+	// Updates counts state and delta messages applied; Keyframes counts the
+	// full states among them.
+	Updates   int
+	Keyframes int
+	// Result is "victory" or "defeat" once the game is over.
+	Result string
+	// Mismatches counts resync keyframes that differed from the state built
+	// up from deltas; see Resync.
+	Mismatches    int
+	awaitingSync  bool
+	sinceKeyframe int
+}
+
+// Dial connects to a server's websocket endpoint, e.g. ws://host:8080/ws.
+func Dial(ctx context.Context, url string) (*Client, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Create opens a new room and waits for the welcome.
+func (c *Client) Create(room Room) error {
+	return c.handshake(InitialMessage{
+		Type:      "create",
+		Code:      room.Code,
+		Seed:      room.Seed,
+		Depth:     room.Depth,
+		Generator: room.Generator,
+		SharedMap: room.SharedMap,
+		SharedXP:  room.SharedXP,
+		Class:     room.Class,
+	})
+}
+
+// Join enters an existing room as class ("" for none) and waits for the
+// welcome.
+func (c *Client) Join(code, class string) error {
+	return c.handshake(InitialMessage{Type: "join", Code: code, Class: class})
+}
+
+// Resume takes back a held player with the token from an earlier welcome.
+func (c *Client) Resume(code, token string) error {
+	return c.handshake(InitialMessage{Type: "resume", Code: code, Token: token})
+}
+
+func (c *Client) handshake(msg InitialMessage) error {
+	if err := c.conn.WriteJSON(msg); err != nil {
+		return err
+	}
+	var reply ServerResponse
+	if err := c.conn.ReadJSON(&reply); err != nil {
+		return err
+	}
+	switch reply.Type {
+	case "welcome":
+		c.ID, c.Code, c.Token, c.Seed = reply.ID, reply.Code, reply.Token, reply.Seed
+		return nil
+	case "error":
+		return &ServerError{Message: reply.Message}
+	default:
+		return fmt.Errorf("unexpected %q reply to %s", reply.Type, msg.Type)
+	}
+}
+
+// Send sends a command, filling in the protocol version.
+func (c *Client) Send(cmd game.Command) error {
+	cmd.Version = game.ProtocolVersion
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.conn.WriteJSON(cmd)
+}
+
+// Resync asks for a keyframe. When it arrives Next checks it against the
+// state the deltas produced and counts any difference in Mismatches.
+func (c *Client) Resync() error {
+	c.mu.Lock()
+	c.awaitingSync = true
+	c.mu.Unlock()
+	return c.Send(game.Command{Type: game.CmdResync})
+}
+
+// Inspect calls fn with the current state while no update is being applied.
+func (c *Client) Inspect(fn func(c *Client)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(c)
+}
+
+// Message is one message received by Next. State updates have already been
+// applied to State when it is returned.
+type Message struct {
+	Type string
+	// Response is set for everything but "state" and "delta".
+	Response ServerResponse
+}
+
+// Next reads the next message from the server.
+func (c *Client) Next() (Message, error) {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return Message{}, err
+	}
+	var envelope struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return Message{}, fmt.Errorf("decoding message: %w", err)
+	}
+	msg := Message{Type: envelope.Type}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch envelope.Type {
+	case "state":
+		var state game.GameStateForJSON
+		if err := json.Unmarshal(envelope.Data, &state); err != nil {
+			return msg, fmt.Errorf("decoding state: %w", err)
+		}
+		// Keyframes that are due anyway (interval or new floor) replace an
+		// update rather than repeat it, so only the reply to Resync compares.
+		if c.awaitingSync && c.sinceKeyframe < game.KeyframeInterval && c.State.Depth == state.Depth {
+			c.awaitingSync = false
+			if !sameState(c.State, state) {
+				c.Mismatches++
+			}
+		}
+		c.State = state
+		c.Updates++
+		c.Keyframes++
+		c.sinceKeyframe = 0
+	case "delta":
+		if c.State.Dungeon == nil {
+			return msg, fmt.Errorf("delta before the first keyframe")
+		}
+		var delta game.StateDelta
+		if err := json.Unmarshal(envelope.Data, &delta); err != nil {
+			return msg, fmt.Errorf("decoding delta: %w", err)
+		}
+		if err := applyDelta(&c.State, delta); err != nil {
+			return msg, err
+		}
+		c.Updates++
+		c.sinceKeyframe++
+	default:
+		if err := json.Unmarshal(data, &msg.Response); err != nil {
+			return msg, fmt.Errorf("decoding %s: %w", envelope.Type, err)
+		}
+		if msg.Type == "gameOver" {
+			c.Result = msg.Response.Result
+		}
+	}
+	return msg, nil
+}
+
+// Me returns this client's player in State, or nil before the first update.
+func (c *Client) Me() *game.Player {
+	return c.State.Players[c.ID]
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
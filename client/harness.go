@@ -0,0 +1,296 @@
+package client
+
+import (
+	"context"
+	"dunExpo/game"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bot plays one player with a strategy, checking the invariants on every
+// update it receives.
+type Bot struct {
+	Client   *Client
+	Strategy Strategy
+	Rand     *rand.Rand
+	// Delay is the pause between commands.
+	Delay time.Duration
+	// MaxCommands stops the bot after sending this many commands.
+	MaxCommands int
+	// ResyncEvery asks for a keyframe after this many commands to check the
+	// state built from deltas; zero never asks.
+	ResyncEvery int
+}
+
+// Outcome is how a bot's game went.
+type Outcome struct {
+	Strategy   string
+	PlayerID   string
+	Result     string
+	Status     string
+	HP         int
+	Depth      int
+	Commands   int
+	Updates    int
+	Keyframes  int
+	Violations []string
+}
+
+// maxViolations caps how many invariant failures an outcome keeps.
+const maxViolations = 20
+
+// Run plays until the game ends, the player is defeated, MaxCommands have
+// been sent or ctx is done. It closes the connection before returning.
+func (b *Bot) Run(ctx context.Context) (Outcome, error) {
+	c := b.Client
+	out := Outcome{Strategy: b.Strategy.Name(), PlayerID: c.ID}
+	updates := make(chan struct{}, 1)
+	readErr := make(chan error, 1)
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			msg, err := c.Next()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if msg.Type == "gameOver" || msg.Type == "shutdown" {
+				readErr <- nil
+				return
+			}
+			if msg.Type != "state" && msg.Type != "delta" {
+				continue
+			}
+			c.Inspect(func(c *Client) {
+				for _, problem := range CheckInvariants(&c.State, c.ID) {
+					if len(out.Violations) < maxViolations {
+						out.Violations = append(out.Violations, problem)
+					}
+				}
+			})
+			select {
+			case updates <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	defer c.Close()
+
+	// A bot waits for an update after each command, so N bots send at most
+	// N commands per update instead of flooding the session. If nothing
+	// arrives for a while it acts anyway.
+	stall := time.NewTimer(0)
+	defer stall.Stop()
+	fresh := false
+	var err error
+	for err == nil && out.Commands < b.MaxCommands {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			continue
+		case err = <-readErr:
+			if err == nil {
+				err = errGameOver
+			}
+			continue
+		case <-updates:
+			fresh = true
+		case <-stall.C:
+			fresh = true
+		}
+		if !fresh {
+			continue
+		}
+		time.Sleep(b.Delay)
+		var cmd game.Command
+		alive := false
+		c.Inspect(func(c *Client) {
+			me := c.Me()
+			if me == nil || c.State.Dungeon == nil {
+				return
+			}
+			alive = me.Status == "playing" || me.Status == "targeting"
+			if alive {
+				cmd = b.Strategy.Decide(View{State: &c.State, Me: me, Rand: b.Rand})
+			}
+		})
+		if !alive {
+			c.Inspect(func(c *Client) {
+				if me := c.Me(); me != nil && me.Status == "defeated" {
+					err = errDefeated
+				}
+			})
+			stall.Reset(b.patience())
+			continue
+		}
+		if sendErr := c.Send(cmd); sendErr != nil {
+			err = sendErr
+			continue
+		}
+		out.Commands++
+		if b.ResyncEvery > 0 && out.Commands%b.ResyncEvery == 0 {
+			if syncErr := c.Resync(); syncErr != nil {
+				err = syncErr
+				continue
+			}
+		}
+		fresh = false
+		stall.Reset(b.patience())
+	}
+	c.Close()
+	<-readerDone
+	if errors.Is(err, errGameOver) || errors.Is(err, errDefeated) {
+		err = nil
+	}
+	c.Inspect(func(c *Client) {
+		out.Result = c.Result
+		out.Updates = c.Updates
+		out.Keyframes = c.Keyframes
+		out.Depth = c.State.Depth
+		if me := c.Me(); me != nil {
+			out.Status, out.HP = me.Status, me.HP
+		}
+		if c.Mismatches > 0 {
+			out.Violations = append(out.Violations, fmt.Sprintf("%d resync keyframes differed from the state built from deltas", c.Mismatches))
+		}
+	})
+	return out, err
+}
+
+// patience is how long the bot waits for an update before acting anyway.
+func (b *Bot) patience() time.Duration {
+	return 20*b.Delay + 50*time.Millisecond
+}
+
+var (
+	errGameOver = errors.New("game over")
+	errDefeated = errors.New("defeated")
+)
+
+// Harness runs a room full of bots against a server.
+type Harness struct {
+	// URL is the server's websocket endpoint, e.g. ws://127.0.0.1:8080/ws.
+	URL  string
+	Room Room
+	// Strategies has one entry per bot; the first bot creates the room.
+	Strategies []Strategy
+	// Classes, if set, has the class each bot plays, by index; bots past
+	// its end play without a class.
+	Classes     []string
+	Delay       time.Duration
+	MaxCommands int
+	ResyncEvery int
+}
+
+// Report is the result of a harness run.
+type Report struct {
+	Code string
+	Seed int64
+	Bots []Outcome
+}
+
+// Violations lists every invariant failure, prefixed with the bot.
+func (r Report) Violations() []string {
+	var all []string
+	for i, bot := range r.Bots {
+		for _, v := range bot.Violations {
+			all = append(all, fmt.Sprintf("bot %d (%s): %s", i, bot.Strategy, v))
+		}
+	}
+	return all
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "room %s, seed %d\n", r.Code, r.Seed)
+	for i, bot := range r.Bots {
+		result := bot.Result
+		if result == "" {
+			result = "-"
+		}
+		fmt.Fprintf(&b, "bot %d %-9s %-9s hp %4d depth %d result %-8s %4d commands %4d updates %3d keyframes %d violations\n",
+			i, bot.Strategy, bot.Status, bot.HP, bot.Depth, result, bot.Commands, bot.Updates, bot.Keyframes, len(bot.Violations))
+	}
+	return b.String()
+}
+
+// Run creates the room, joins the other bots and plays until every bot has
+// stopped.
+func (h Harness) Run(ctx context.Context) (Report, error) {
+	if len(h.Strategies) == 0 {
+		return Report{}, errors.New("harness has no bots")
+	}
+	room := h.Room
+	random := rand.New(rand.NewSource(room.Seed))
+	if room.Code == "" {
+		const letters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+		code := make([]byte, 4)
+		for i := range code {
+			code[i] = letters[random.Intn(len(letters))]
+		}
+		room.Code = string(code)
+	}
+
+	bots := make([]*Bot, len(h.Strategies))
+	for i, strategy := range h.Strategies {
+		c, err := Dial(ctx, h.URL)
+		class := ""
+		if i < len(h.Classes) {
+			class = h.Classes[i]
+		}
+		if err == nil {
+			if i == 0 {
+				room.Class = class
+				err = c.Create(room)
+			} else {
+				err = c.Join(room.Code, class)
+			}
+		}
+		if err != nil {
+			for _, bot := range bots[:i] {
+				bot.Client.Close()
+			}
+			if c != nil {
+				c.Close()
+			}
+			return Report{}, fmt.Errorf("bot %d: %w", i, err)
+		}
+		bots[i] = &Bot{
+			Client:      c,
+			Strategy:    strategy,
+			Rand:        rand.New(rand.NewSource(random.Int63())),
+			Delay:       h.Delay,
+			MaxCommands: h.MaxCommands,
+			ResyncEvery: h.ResyncEvery,
+		}
+	}
+
+	report := Report{Code: room.Code, Seed: bots[0].Client.Seed, Bots: make([]Outcome, len(bots))}
+	errs := make([]error, len(bots))
+	var wg sync.WaitGroup
+	for i, bot := range bots {
+		wg.Add(1)
+		go func(i int, bot *Bot) {
+			defer wg.Done()
+			report.Bots[i], errs[i] = bot.Run(ctx)
+		}(i, bot)
+	}
+	wg.Wait()
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("bot %d: %v", i, err))
+		}
+	}
+	sort.Strings(failed)
+	if len(failed) > 0 {
+		return report, errors.New(strings.Join(failed, "; "))
+	}
+	return report, nil
+}
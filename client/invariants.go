@@ -0,0 +1,110 @@
+package client
+
+import (
+	"dunExpo/dungeon"
+	"dunExpo/game"
+	"fmt"
+)
+
+// CheckInvariants lists every rule the view of player me breaks. These hold
+// for any correct server no matter what the players do.
+func CheckInvariants(state *game.GameStateForJSON, me string) []string {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(state.Dungeon) != dungeon.MapHeight {
+		fail("map has %d rows, want %d", len(state.Dungeon), dungeon.MapHeight)
+		return problems
+	}
+	for y, row := range state.Dungeon {
+		if len(row) != dungeon.MapWidth {
+			fail("map row %d has %d tiles, want %d", y, len(row), dungeon.MapWidth)
+			return problems
+		}
+	}
+	if state.Depth < 1 || state.Depth > state.MaxDepth {
+		fail("depth %d outside 1..%d", state.Depth, state.MaxDepth)
+	}
+
+	visible := make(map[dungeon.Point]bool, len(state.VisibleTiles))
+	for _, p := range state.VisibleTiles {
+		visible[p] = true
+		if tileAt(state, p) == game.TileUnknown {
+			fail("visible tile %v is unexplored", p)
+		}
+	}
+
+	player, ok := state.Players[me]
+	if !ok {
+		fail("own player %s missing", me)
+	} else {
+		if player.HP > player.MaxHP {
+			fail("HP %d above max %d", player.HP, player.MaxHP)
+		}
+		if (player.Status == "defeated") != (player.HP <= 0) {
+			fail("status %q with %d HP", player.Status, player.HP)
+		}
+		if !walkable(state, player.Position) {
+			fail("standing on tile %d at %v", tileAt(state, player.Position), player.Position)
+		}
+		if player.EquippedWeapon != nil && !inInventory(player, player.EquippedWeapon) {
+			fail("equipped weapon %s not in inventory", player.EquippedWeapon.Name)
+		}
+		if player.EquippedArmor != nil && !inInventory(player, player.EquippedArmor) {
+			fail("equipped armor %s not in inventory", player.EquippedArmor.Name)
+		}
+	}
+
+	taken := make(map[dungeon.Point]string)
+	for id, p := range state.Players {
+		if id != me && p.Inventory != nil {
+			fail("inventory of player %s was sent", short(id))
+		}
+		if id != me && (p.EquippedWeapon != nil || p.EquippedArmor != nil) {
+			fail("equipment of player %s was sent", short(id))
+		}
+		if other, clash := taken[p.Position]; clash {
+			fail("players %s and %s share %v", short(other), short(id), p.Position)
+		}
+		taken[p.Position] = "player " + short(id)
+	}
+	for _, m := range state.Monsters {
+		if !visible[m.Position] {
+			fail("monster %d at %v is out of sight", m.ID, m.Position)
+		}
+		if tileAt(state, m.Position) == dungeon.TileWall {
+			fail("monster %d inside a wall at %v", m.ID, m.Position)
+		}
+		if other, clash := taken[m.Position]; clash {
+			fail("monster %d shares %v with %s", m.ID, m.Position, other)
+		}
+		taken[m.Position] = fmt.Sprintf("monster %d", m.ID)
+	}
+	for _, it := range state.ItemsOnGround {
+		if !visible[it.Position] {
+			fail("item at %v is out of sight", it.Position)
+		}
+	}
+	if state.ExitPos != nil && tileAt(state, *state.ExitPos) == game.TileUnknown {
+		fail("exit %v sent before it was explored", *state.ExitPos)
+	}
+	return problems
+}
+
+func inInventory(p *game.Player, item *game.Item) bool {
+	for _, it := range p.Inventory {
+		if *it == *item {
+			return true
+		}
+	}
+	return false
+}
+
+func short(id string) string {
+	if len(id) > 4 {
+		return id[:4]
+	}
+	return id
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"dunExpo/dungeon"
+	"dunExpo/game"
+)
+
+var steps = []struct {
+	dir    string
+	dx, dy int
+}{
+	{"north", 0, -1},
+	{"south", 0, 1},
+	{"west", -1, 0},
+	{"east", 1, 0},
+}
+
+// tileAt returns the tile at p, or TileUnknown off the map.
+func tileAt(state *game.GameStateForJSON, p dungeon.Point) int {
+	if p.Y < 0 || p.Y >= len(state.Dungeon) || p.X < 0 || p.X >= len(state.Dungeon[p.Y]) {
+		return game.TileUnknown
+	}
+	return state.Dungeon[p.Y][p.X]
+}
+
+// walkable reports whether p is known ground a player could stand on.
+func walkable(state *game.GameStateForJSON, p dungeon.Point) bool {
+	tile := tileAt(state, p)
+	return tile != game.TileUnknown && tile != dungeon.TileWall
+}
+
+// occupied reports whether a visible monster or another player stands on p.
+func occupied(state *game.GameStateForJSON, p dungeon.Point, me string) bool {
+	for _, m := range state.Monsters {
+		if m.Position == p {
+			return true
+		}
+	}
+	for id, other := range state.Players {
+		if id != me && other.Position == p {
+			return true
+		}
+	}
+	return false
+}
+
+// route finds the shortest known path from start to the nearest tile for
+// which goal is true, walking around monsters and other players. It returns
+// the direction of the first step.
+func route(state *game.GameStateForJSON, me string, start dungeon.Point, goal func(dungeon.Point) bool) (string, bool) {
+	type visit struct {
+		p     dungeon.Point
+		first string
+	}
+	seen := map[dungeon.Point]bool{start: true}
+	queue := []visit{{p: start}}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, s := range steps {
+			next := dungeon.Point{X: v.p.X + s.dx, Y: v.p.Y + s.dy}
+			if seen[next] || !walkable(state, next) {
+				continue
+			}
+			seen[next] = true
+			first := v.first
+			if first == "" {
+				first = s.dir
+			}
+			if goal(next) {
+				return first, true
+			}
+			if occupied(state, next, me) {
+				continue
+			}
+			queue = append(queue, visit{p: next, first: first})
+		}
+	}
+	return "", false
+}
+
+// frontier reports whether p is known ground next to unexplored map.
+func frontier(state *game.GameStateForJSON, p dungeon.Point) bool {
+	if !walkable(state, p) {
+		return false
+	}
+	for _, s := range steps {
+		if tileAt(state, dungeon.Point{X: p.X + s.dx, Y: p.Y + s.dy}) == game.TileUnknown {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"dunExpo/game"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// applyDelta brings state up to date with a delta, the inverse of
+// game.ClientView.Update.
+func applyDelta(state *game.GameStateForJSON, delta game.StateDelta) error {
+	for _, t := range delta.Tiles {
+		if t.Y < 0 || t.Y >= len(state.Dungeon) || t.X < 0 || t.X >= len(state.Dungeon[t.Y]) {
+			return fmt.Errorf("delta tile (%d,%d) is off the map", t.X, t.Y)
+		}
+		state.Dungeon[t.Y][t.X] = t.Tile
+	}
+	if delta.ExitPos != nil {
+		state.ExitPos = delta.ExitPos
+	}
+
+	for _, raw := range delta.Monsters {
+		var m game.Monster
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return fmt.Errorf("decoding monster: %w", err)
+		}
+		replaced := false
+		for i, existing := range state.Monsters {
+			if existing.ID == m.ID {
+				state.Monsters[i] = &m
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			state.Monsters = append(state.Monsters, &m)
+		}
+	}
+	if len(delta.RemovedMonsters) > 0 {
+		removed := make(map[int]bool, len(delta.RemovedMonsters))
+		for _, id := range delta.RemovedMonsters {
+			removed[id] = true
+		}
+		kept := state.Monsters[:0]
+		for _, m := range state.Monsters {
+			if !removed[m.ID] {
+				kept = append(kept, m)
+			}
+		}
+		state.Monsters = kept
+	}
+
+	if state.Players == nil {
+		state.Players = make(map[string]*game.Player)
+	}
+	for _, raw := range delta.Players {
+		var p game.Player
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return fmt.Errorf("decoding player: %w", err)
+		}
+		state.Players[p.ID] = &p
+	}
+	for _, id := range delta.RemovedPlayers {
+		delete(state.Players, id)
+	}
+
+	for _, raw := range delta.ItemsOnGround {
+		var it game.ItemOnGroundJSON
+		if err := json.Unmarshal(raw, &it); err != nil {
+			return fmt.Errorf("decoding item: %w", err)
+		}
+		replaced := false
+		for i, existing := range state.ItemsOnGround {
+			if existing.Position == it.Position {
+				state.ItemsOnGround[i] = it
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			state.ItemsOnGround = append(state.ItemsOnGround, it)
+		}
+	}
+	if len(delta.RemovedItems) > 0 {
+		kept := state.ItemsOnGround[:0]
+		for _, it := range state.ItemsOnGround {
+			gone := false
+			for _, pos := range delta.RemovedItems {
+				if it.Position == pos {
+					gone = true
+					break
+				}
+			}
+			if !gone {
+				kept = append(kept, it)
+			}
+		}
+		state.ItemsOnGround = kept
+	}
+
+	state.Events = delta.Events
+
+	fields := []struct {
+		raw json.RawMessage
+		dst interface{}
+	}{
+		{delta.Log, &state.Log},
+		{delta.HighlightedTiles, &state.HighlightedTiles},
+		{delta.VisibleTiles, &state.VisibleTiles},
+	}
+	for _, f := range fields {
+		if f.raw == nil {
+			continue
+		}
+		if err := json.Unmarshal(f.raw, f.dst); err != nil {
+			return fmt.Errorf("decoding delta: %w", err)
+		}
+	}
+	return nil
+}
+
+// sameState compares two views as the server would send them, ignoring the
+// order of monsters and items, which deltas do not preserve.
+func sameState(a, b game.GameStateForJSON) bool {
+	rawA, errA := json.Marshal(normalized(a))
+	rawB, errB := json.Marshal(normalized(b))
+	return errA == nil && errB == nil && string(rawA) == string(rawB)
+}
+
+func normalized(s game.GameStateForJSON) game.GameStateForJSON {
+	s.Monsters = append([]*game.Monster(nil), s.Monsters...)
+	sort.Slice(s.Monsters, func(i, j int) bool { return s.Monsters[i].ID < s.Monsters[j].ID })
+	s.ItemsOnGround = append([]game.ItemOnGroundJSON(nil), s.ItemsOnGround...)
+	sort.Slice(s.ItemsOnGround, func(i, j int) bool {
+		a, b := s.ItemsOnGround[i].Position, s.ItemsOnGround[j].Position
+		return a.Y < b.Y || (a.Y == b.Y && a.X < b.X)
+	})
+	// Events belong to the update that carried them, not to the state.
+	s.Events = nil
+	if len(s.Log) == 0 {
+		s.Log = nil
+	}
+	if len(s.HighlightedTiles) == 0 {
+		s.HighlightedTiles = nil
+	}
+	if len(s.VisibleTiles) == 0 {
+		s.VisibleTiles = nil
+	}
+	return s
+}
@@ -0,0 +1,211 @@
+// Command client is a terminal client for the game server.
+//
+//	go run ./cmd/client -create ABCD -class warrior  # open a room
+//	go run ./cmd/client -join ABCD -class cleric     # join a friend's room
+//	go run ./cmd/client -resume ABCD -token T        # come back after a drop
+//
+// Move with the arrow keys or WASD (walking into a monster attacks it),
+// g picks up, e cycles weapons, x drops the equipped weapon, f aims and
+// fires a ranged weapon, c uses the class ability, 1-9 use the matching
+// inventory item (bombs are thrown at the closest monster), Esc cancels
+// aiming, r resyncs and q quits.
+package main
+
+import (
+	"context"
+	"dunExpo/client"
+	"dunExpo/game"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	ui "github.com/gizak/termui/v3"
+)
+
+func main() {
+	url := flag.String("url", "ws://localhost:8080/ws", "server websocket URL")
+	create := flag.String("create", "", "create a room with this code")
+	join := flag.String("join", "", "join the room with this code")
+	resume := flag.String("resume", "", "resume a dropped player in the room with this code")
+	token := flag.String("token", "", "reconnect token for -resume")
+	seed := flag.Int64("seed", 0, "dungeon seed for -create (0 picks one)")
+	depth := flag.Int("depth", 0, "number of floors for -create (0 is the server default)")
+	generator := flag.String("generator", "", "map generator for -create: walk, bsp or caves")
+	shared := flag.Bool("shared", false, "share explored maps between nearby allies (-create)")
+	sharedXP := flag.Bool("shared-xp", false, "split kill XP between the whole party (-create)")
+	class := flag.String("class", "", "class to play with -create or -join: warrior, ranger, scout or cleric")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	c, err := client.Dial(ctx, *url)
+	cancel()
+	if err != nil {
+		log.Fatalf("connecting to %s: %v", *url, err)
+	}
+	switch {
+	case *create != "":
+		err = c.Create(client.Room{Code: *create, Seed: *seed, Depth: *depth, Generator: *generator, SharedMap: *shared, SharedXP: *sharedXP, Class: *class})
+	case *join != "":
+		err = c.Join(*join, *class)
+	case *resume != "":
+		err = c.Resume(*resume, *token)
+	default:
+		c.Close()
+		fmt.Fprintln(os.Stderr, "one of -create, -join or -resume is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		c.Close()
+		log.Fatal(err)
+	}
+
+	if err := ui.Init(); err != nil {
+		c.Close()
+		log.Fatalf("starting the terminal UI: %v", err)
+	}
+	screen := newScreen()
+	status := run(c, screen)
+	ui.Close()
+	c.Close()
+	fmt.Println(status)
+	fmt.Printf("Rejoin with: -resume %s -token %s\n", c.Code, c.Token)
+}
+
+// run reads the server in the background and the keyboard in the
+// foreground until the player quits or the connection ends. It returns a
+// line to print once the UI is gone.
+func run(c *client.Client, screen *screen) string {
+	updates := make(chan client.Message)
+	done := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := c.Next()
+			if err != nil {
+				done <- err
+				return
+			}
+			updates <- msg
+		}
+	}()
+
+	aiming := false
+	notice := fmt.Sprintf("Room %s (seed %d). Press ? for help.", c.Code, c.Seed)
+	redraw := func() {
+		c.Inspect(func(c *client.Client) {
+			screen.render(c, notice, aiming)
+		})
+	}
+	redraw()
+	keys := ui.PollEvents()
+	for {
+		select {
+		case err := <-done:
+			return fmt.Sprintf("Disconnected: %v", err)
+		case msg := <-updates:
+			switch msg.Type {
+			case "error":
+				notice = msg.Response.Message
+			case "gameOver":
+				notice = strings.ToUpper(msg.Response.Result) + "! Press q to leave."
+			case "shutdown":
+				notice = msg.Response.Message
+			}
+			c.Inspect(func(c *client.Client) {
+				if me := c.Me(); me != nil {
+					aiming = me.Status == "targeting"
+				}
+			})
+			redraw()
+		case e := <-keys:
+			if e.Type == ui.ResizeEvent {
+				screen.resize()
+				redraw()
+				continue
+			}
+			if e.Type != ui.KeyboardEvent {
+				continue
+			}
+			if e.ID == "q" || e.ID == "<C-c>" {
+				return "Left the game."
+			}
+			if e.ID == "?" {
+				notice = helpText
+				redraw()
+				continue
+			}
+			cmd, ok := keyCommand(e.ID, aiming)
+			if len(e.ID) == 1 && e.ID[0] >= '1' && e.ID[0] <= '9' {
+				c.Inspect(func(c *client.Client) {
+					cmd, ok = useCommand(c, int(e.ID[0]-'1'))
+				})
+			}
+			if !ok {
+				continue
+			}
+			if err := c.Send(cmd); err != nil {
+				return fmt.Sprintf("Disconnected: %v", err)
+			}
+		}
+	}
+}
+
+const helpText = "Arrows/WASD move and attack, g pick up, e equip, x drop, f aim/fire, c ability, 1-9 use item, Esc cancel, r resync, q quit"
+
+// useCommand uses the nth inventory item on the player, or throws it at the
+// closest monster in view if it is a bomb.
+func useCommand(c *client.Client, n int) (game.Command, bool) {
+	me := c.Me()
+	if me == nil || n >= len(me.Inventory) || !me.Inventory[n].IsConsumable {
+		return game.Command{}, false
+	}
+	item := me.Inventory[n]
+	cmd := game.Command{Type: game.CmdUse, Item: item.Name}
+	if item.Effect == game.EffectBomb {
+		closest := -1
+		for _, m := range c.State.Monsters {
+			if d := game.Distance(me.Position, m.Position); closest == -1 || d < closest {
+				closest = d
+				target := m.Position
+				cmd.Target = &target
+			}
+		}
+	}
+	return cmd, true
+}
+
+// keyCommand maps a key to the command it sends.
+func keyCommand(key string, aiming bool) (game.Command, bool) {
+	moves := map[string]string{
+		"<Up>": "north", "w": "north",
+		"<Down>": "south", "s": "south",
+		"<Left>": "west", "a": "west",
+		"<Right>": "east", "d": "east",
+	}
+	if dir, ok := moves[key]; ok && !aiming {
+		return game.Command{Type: game.CmdMove, Dir: dir}, true
+	}
+	switch key {
+	case "g", "<Space>":
+		return game.Command{Type: game.CmdPickup}, true
+	case "e":
+		return game.Command{Type: game.CmdEquip}, true
+	case "x":
+		return game.Command{Type: game.CmdDrop}, true
+	case "f", "<Enter>":
+		if aiming {
+			return game.Command{Type: game.CmdFire}, true
+		}
+		return game.Command{Type: game.CmdAim}, true
+	case "c":
+		return game.Command{Type: game.CmdAbility}, true
+	case "<Escape>":
+		return game.Command{Type: game.CmdCancel}, true
+	case "r":
+		return game.Command{Type: game.CmdResync}, true
+	}
+	return game.Command{}, false
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"dunExpo/client"
+	"dunExpo/dungeon"
+	"dunExpo/game"
+	"fmt"
+	"image"
+	"sort"
+	"strconv"
+	"strings"
+
+	ui "github.com/gizak/termui/v3"
+	"github.com/gizak/termui/v3/widgets"
+)
+
+// sidebarWidth is how many columns the status and log panels take.
+const sidebarWidth = 38
+
+// screen lays out the map on the left with status, log and help panels.
+type screen struct {
+	dungeonMap *mapView
+	status     *widgets.Paragraph
+	log        *widgets.List
+	notice     *widgets.Paragraph
+}
+
+func newScreen() *screen {
+	s := &screen{
+		dungeonMap: newMapView(),
+		status:     widgets.NewParagraph(),
+		log:        widgets.NewList(),
+		notice:     widgets.NewParagraph(),
+	}
+	s.status.Title = " Player "
+	s.log.Title = " Log "
+	s.log.WrapText = true
+	s.notice.Border = false
+	s.resize()
+	return s
+}
+
+func (s *screen) resize() {
+	width, height := ui.TerminalDimensions()
+	mapWidth := width - sidebarWidth
+	if mapWidth < 10 {
+		mapWidth = 10
+	}
+	s.dungeonMap.SetRect(0, 0, mapWidth, height-1)
+	s.status.SetRect(mapWidth, 0, width, 18)
+	s.log.SetRect(mapWidth, 18, width, height-1)
+	s.notice.SetRect(0, height-1, width, height)
+}
+
+// render draws everything from c's state. Call it inside c.Inspect.
+func (s *screen) render(c *client.Client, notice string, aiming bool) {
+	state := &c.State
+	me := c.Me()
+	s.dungeonMap.state, s.dungeonMap.me = state, me
+	s.dungeonMap.Title = fmt.Sprintf(" Depth %d/%d ", state.Depth, state.MaxDepth)
+
+	var b strings.Builder
+	if me == nil {
+		b.WriteString("Waiting for the game...")
+	} else {
+		fmt.Fprintf(&b, "[%s](fg:white,mod:bold)  %s\n", c.ID[:4], me.Status)
+		fmt.Fprintf(&b, "HP     %s %d/%d\n", hpBar(me.HP, me.MaxHP), me.HP, me.MaxHP)
+		if next := game.NextLevelXP(me.Level); next > 0 {
+			fmt.Fprintf(&b, "Level  %d   XP %d/%d\n", me.Level, me.XP, next)
+		} else {
+			fmt.Fprintf(&b, "Level  %d   XP %d (max)\n", me.Level, me.XP)
+		}
+		fmt.Fprintf(&b, "Attack %d   Vision %d\n", me.AttackPower(), me.VisionRadius+me.VisionBonus)
+		if class, ok := game.Classes[me.Class]; ok {
+			ready := "ready"
+			if me.AbilityCooldown > 0 {
+				ready = fmt.Sprintf("%d turns", me.AbilityCooldown)
+			}
+			fmt.Fprintf(&b, "%s, %s: %s\n", class.Name, class.Ability, ready)
+		}
+		fmt.Fprintf(&b, "Weapon %s\n", itemName(me.EquippedWeapon))
+		fmt.Fprintf(&b, "Armor  %s\n", itemName(me.EquippedArmor))
+		b.WriteString("Inventory:\n")
+		if len(me.Inventory) == 0 {
+			b.WriteString("  (empty)\n")
+		}
+		for i, item := range me.Inventory {
+			fmt.Fprintf(&b, " %d %s\n", i+1, itemName(item))
+		}
+		if aiming {
+			b.WriteString("[Aiming: f to fire, Esc to cancel](fg:yellow)\n")
+		}
+		var allies []string
+		for id, p := range state.Players {
+			if id != c.ID {
+				allies = append(allies, fmt.Sprintf("  %s %s HP %d", id[:4], p.Status, p.HP))
+			}
+		}
+		sort.Strings(allies)
+		if len(allies) > 0 {
+			b.WriteString("Allies:\n" + strings.Join(allies, "\n"))
+		}
+	}
+	s.status.Text = b.String()
+	s.log.Rows = append([]string(nil), state.Log...)
+	s.notice.Text = notice
+	ui.Render(s.dungeonMap, s.status, s.log, s.notice)
+}
+
+func hpBar(hp, max int) string {
+	const width = 10
+	if max <= 0 {
+		return ""
+	}
+	filled := hp * width / max
+	if filled < 0 {
+		filled = 0
+	}
+	color := "green"
+	if hp*3 < max {
+		color = "red"
+	}
+	return fmt.Sprintf("[%s](fg:%s)%s", strings.Repeat("|", filled), color, strings.Repeat(".", width-filled))
+}
+
+func itemName(item *game.Item) string {
+	if item == nil {
+		return "-"
+	}
+	switch {
+	case item.IsWeapon:
+		return fmt.Sprintf("%s (dmg %d, range %d)", item.Name, item.Damage, item.Range)
+	case item.IsArmor:
+		return fmt.Sprintf("%s (%d)", item.Name, item.Durability)
+	case item.IsConsumable:
+		return fmt.Sprintf("%s x%d", item.Name, item.Count)
+	}
+	return item.Name
+}
+
+// mapView draws the player's view of the dungeon, scrolled to keep them in
+// sight. Tiles out of view are drawn dimmed; unexplored ones are blank.
+type mapView struct {
+	ui.Block
+	state *game.GameStateForJSON
+	me    *game.Player
+}
+
+func newMapView() *mapView {
+	return &mapView{Block: *ui.NewBlock()}
+}
+
+func (m *mapView) Draw(buf *ui.Buffer) {
+	m.Block.Draw(buf)
+	if m.state == nil || m.state.Dungeon == nil {
+		return
+	}
+	inner := m.Inner
+	origin := m.origin(inner)
+
+	visible := make(map[dungeon.Point]bool, len(m.state.VisibleTiles))
+	for _, p := range m.state.VisibleTiles {
+		visible[p] = true
+	}
+	highlighted := make(map[dungeon.Point]bool, len(m.state.HighlightedTiles))
+	for _, p := range m.state.HighlightedTiles {
+		highlighted[p] = true
+	}
+	set := func(p dungeon.Point, r rune, style ui.Style) {
+		at := image.Pt(inner.Min.X+p.X-origin.X, inner.Min.Y+p.Y-origin.Y)
+		if !at.In(inner) {
+			return
+		}
+		if highlighted[p] {
+			style.Bg = ui.ColorRed
+		}
+		buf.SetCell(ui.NewCell(r, style), at)
+	}
+
+	for y, row := range m.state.Dungeon {
+		for x, tile := range row {
+			p := dungeon.Point{X: x, Y: y}
+			r, color := tileLook(tile)
+			if r == 0 {
+				continue
+			}
+			if !visible[p] {
+				color = ui.Color(8)
+			}
+			set(p, r, ui.NewStyle(color))
+		}
+	}
+	for _, it := range m.state.ItemsOnGround {
+		if it.Item != nil {
+			set(it.Position, it.Item.Rune, ui.NewStyle(ansiColor(it.Item.Color)))
+		}
+	}
+	for _, monster := range m.state.Monsters {
+		if monster.Template != nil {
+			set(monster.Position, monster.Template.Rune, ui.NewStyle(ansiColor(monster.Template.Color), ui.ColorClear, ui.ModifierBold))
+		}
+	}
+	for id, p := range m.state.Players {
+		style := ui.NewStyle(ui.ColorCyan, ui.ColorClear, ui.ModifierBold)
+		r := '@'
+		if m.me != nil && id == m.me.ID {
+			style.Fg = ui.ColorYellow
+		}
+		if p.Status == "defeated" {
+			r, style = '%', ui.NewStyle(ui.Color(8))
+		}
+		set(p.Position, r, style)
+	}
+}
+
+// origin is the map position drawn at the top left corner, chosen so that
+// the player stays inside the panel when the map is bigger than it.
+func (m *mapView) origin(inner image.Rectangle) dungeon.Point {
+	var origin dungeon.Point
+	if m.me == nil {
+		return origin
+	}
+	scroll := func(pos, size, total int) int {
+		if total <= size {
+			return 0
+		}
+		start := pos - size/2
+		if start < 0 {
+			start = 0
+		}
+		if start > total-size {
+			start = total - size
+		}
+		return start
+	}
+	origin.X = scroll(m.me.Position.X, inner.Dx(), dungeon.MapWidth)
+	origin.Y = scroll(m.me.Position.Y, inner.Dy(), dungeon.MapHeight)
+	return origin
+}
+
+// tileLook returns how a tile is drawn; unexplored tiles have no rune.
+func tileLook(tile int) (rune, ui.Color) {
+	switch tile {
+	case dungeon.TileWall:
+		return '#', ansiColor(dungeon.ColorGrey)
+	case dungeon.TileFloor:
+		return '.', ansiColor(dungeon.ColorWhite)
+	case dungeon.TileExit:
+		return '>', ansiColor(dungeon.ColorYellow)
+	case dungeon.TileHealth:
+		return '+', ansiColor(dungeon.ColorGreen)
+	}
+	return 0, ui.ColorClear
+}
+
+// ansiColor turns the ANSI escape codes used by content packs, such as
+// "\x1b[91m", into terminal colours: 30-37 are the basic colours and 90-97
+// their bright versions.
+func ansiColor(code string) ui.Color {
+	code = strings.TrimSuffix(strings.TrimPrefix(code, "\x1b["), "m")
+	n, err := strconv.Atoi(code)
+	switch {
+	case err != nil:
+		return ui.ColorWhite
+	case n >= 30 && n <= 37:
+		return ui.Color(n - 30)
+	case n >= 90 && n <= 97:
+		return ui.Color(n - 90 + 8)
+	}
+	return ui.ColorWhite
+}
@@ -0,0 +1,51 @@
+// Command replay re-simulates a recorded session and checks that it ends in
+// the state the server recorded.
+//
+//	go run ./cmd/replay [-content dir] ABCD-20251014T132705.replay
+package main
+
+import (
+	"dunExpo/game"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	contentDir := flag.String("content", "./content", "content pack directory the server ran with")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-content dir] file.replay\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if _, err := game.LoadContentDir(*contentDir); err != nil {
+		log.Fatalf("loading content packs from %s: %v", *contentDir, err)
+	}
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	result, err := game.RunReplay(f)
+	if err != nil {
+		log.Fatalf("replaying %s: %v", flag.Arg(0), err)
+	}
+	fmt.Printf("seed %d, generator %s, depth %d\n", result.Config.Seed, result.Config.Generator, result.Config.MaxDepth)
+	fmt.Printf("%d events, %d turns, result %q\n", result.Events, result.Turns, result.Result)
+	fmt.Printf("final state %s\n", result.Hash)
+	switch {
+	case result.Expected == "":
+		fmt.Println("recording has no end event; nothing to verify")
+		os.Exit(1)
+	case result.Matches():
+		fmt.Println("OK: replay matches the recorded final state")
+	default:
+		fmt.Printf("MISMATCH: recorded final state %s\n", result.Expected)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"dunExpo/game"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxMessageSize caps a single client message; commands are tiny.
+const maxMessageSize = 4096
+
+// ConnConfig holds the keepalive and buffering settings applied to every
+// client connection.
+type ConnConfig struct {
+	// PingInterval is how often the server pings an idle client.
+	PingInterval time.Duration
+	// PongWait is how long a connection may go without a message or pong
+	// before it is treated as dead. It must exceed PingInterval.
+	PongWait time.Duration
+	// WriteWait bounds every single write.
+	WriteWait time.Duration
+	// SendQueue is how many outbound messages may wait for the writer.
+	SendQueue int
+}
+
+var DefaultConnConfig = ConnConfig{
+	PingInterval: 25 * time.Second,
+	PongWait:     60 * time.Second,
+	WriteWait:    10 * time.Second,
+	SendQueue:    32,
+}
+
+// ServerConfig holds the settings every session is created with.
+type ServerConfig struct {
+	// ReconnectGrace is how long a session holds a disconnected player.
+	ReconnectGrace time.Duration
+	Conn           ConnConfig
+	// ShutdownTimeout bounds a graceful shutdown.
+	ShutdownTimeout time.Duration
+	// Snapshot, when set, saves a session during shutdown so that it can be
+	// resumed after a restart.
+	Snapshot func(*Session) error
+	// ReplayDir, when set, is where new sessions record their replays.
+	ReplayDir string
+}
+
+type Client struct {
+	Conn       *websocket.Conn
+	PlayerID   string
+	CmdChannel chan<- game.ClientCommand
+	// view tracks what this client has been sent; guarded by Session.mux.
+	view game.ClientView
+
+	cfg       ConnConfig
+	wake      chan struct{}
+	quit      chan struct{}
+	closeOnce sync.Once
+
+	// mu guards the outbound queue and the flags below.
+	mu    sync.Mutex
+	queue []outbound
+	// resync is set when state frames were dropped or refused, so the next
+	// one must be a keyframe.
+	resync bool
+	// draining closes the connection once the queue has been written.
+	draining bool
+}
+
+// outbound is a queued message, already encoded so that the writer never
+// reads game state. State frames may be dropped under backpressure;
+// everything else is delivered or the client is cut off.
+type outbound struct {
+	data  []byte
+	state bool
+}
+
+// newClient wraps a freshly upgraded connection and starts its writer.
+// Everything sent to the client, from the lobby handshake on, goes through
+// the queue so that only the writer touches the connection.
+func newClient(conn *websocket.Conn, cfg ConnConfig) *Client {
+	c := &Client{
+		Conn: conn,
+		cfg:  cfg,
+		wake: make(chan struct{}, 1),
+		quit: make(chan struct{}),
+	}
+	go c.writePump()
+	return c
+}
+
+// attach binds the connection to a player in a session.
+func (c *Client) attach(playerID string, commands chan<- game.ClientCommand) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.PlayerID = playerID
+	c.CmdChannel = commands
+}
+
+// name identifies the client in logs, before and after it has a player.
+func (c *Client) name() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.PlayerID == "" {
+		return c.Conn.RemoteAddr().String()
+	}
+	return c.PlayerID[0:4]
+}
+
+// Send queues a message that must not be lost, such as a welcome, an error
+// or the game-over notice. If the queue is full, queued state frames are
+// dropped to make room; if that is not enough the client is disconnected.
+func (c *Client) Send(msg interface{}) bool {
+	return c.enqueue(msg, false)
+}
+
+// SendState queues a state or delta frame. When the queue is full the frame
+// is dropped along with any queued ones, which are stale by now, and false is
+// returned: the caller should send a fresh keyframe instead.
+func (c *Client) SendState(msg interface{}) bool {
+	return c.enqueue(msg, true)
+}
+
+// NeedsKeyframe reports, once, that state frames were dropped or refused
+// since the last call.
+func (c *Client) NeedsKeyframe() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resync := c.resync
+	c.resync = false
+	return resync
+}
+
+func (c *Client) enqueue(msg interface{}, state bool) bool {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("[ERROR] encoding message for %s: %v", c.name(), err)
+		return false
+	}
+	out := outbound{data: data, state: state}
+	c.mu.Lock()
+	if c.closed() || c.draining {
+		c.mu.Unlock()
+		return false
+	}
+	if len(c.queue) >= c.cfg.SendQueue {
+		c.dropStateFrames()
+		if out.state {
+			// The caller's view already counts this frame as sent, so the
+			// next one must be a keyframe even if no queued frame was dropped.
+			c.resync = true
+			c.mu.Unlock()
+			log.Printf("[WARN] %s is falling behind, dropped stale state frames", c.name())
+			return false
+		}
+	}
+	if len(c.queue) >= c.cfg.SendQueue {
+		c.mu.Unlock()
+		log.Printf("[WARN] outbound queue overflow for %s, disconnecting", c.name())
+		c.Close()
+		return false
+	}
+	c.queue = append(c.queue, out)
+	c.mu.Unlock()
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// dropStateFrames removes queued state frames. The caller must hold c.mu.
+func (c *Client) dropStateFrames() {
+	kept := c.queue[:0]
+	for _, out := range c.queue {
+		if !out.state {
+			kept = append(kept, out)
+		}
+	}
+	if len(kept) < len(c.queue) {
+		c.resync = true
+	}
+	for i := len(kept); i < len(c.queue); i++ {
+		c.queue[i] = outbound{}
+	}
+	c.queue = kept
+}
+
+func (c *Client) closed() bool {
+	select {
+	case <-c.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// CloseWhenSent lets the writer deliver what is queued, then closes the
+// connection. It is used to reject a handshake with an error message.
+func (c *Client) CloseWhenSent() {
+	c.mu.Lock()
+	c.draining = true
+	c.mu.Unlock()
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the writer and closes the connection, which also ends Listen.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.quit)
+		c.Conn.Close()
+	})
+}
+
+// writePump is the only goroutine that writes to the connection. It drains
+// the outbound queue and pings the client so dead peers are noticed.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(c.cfg.PingInterval)
+	defer func() {
+		ticker.Stop()
+		c.Close()
+	}()
+	for {
+		select {
+		case <-c.quit:
+			return
+		case <-c.wake:
+			c.mu.Lock()
+			batch := c.queue
+			c.queue = nil
+			draining := c.draining
+			c.mu.Unlock()
+			for _, out := range batch {
+				c.Conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteWait))
+				if err := c.Conn.WriteMessage(websocket.TextMessage, out.data); err != nil {
+					log.Printf("[ERROR] write to %s: %v", c.name(), err)
+					return
+				}
+			}
+			if draining {
+				c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(c.cfg.WriteWait))
+				return
+			}
+		case <-ticker.C:
+			if err := c.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.cfg.WriteWait)); err != nil {
+				log.Printf("[DEBUG] ping to %s failed: %v", c.name(), err)
+				return
+			}
+		}
+	}
+}
+
+// keepAlive arms the read deadline and extends it whenever the client
+// answers a ping.
+func keepAlive(conn *websocket.Conn, cfg ConnConfig) {
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	})
+}
+
+// loadEnv overrides the defaults from RECONNECT_GRACE, PING_INTERVAL,
+// PONG_WAIT, WRITE_WAIT, SHUTDOWN_TIMEOUT and SEND_QUEUE.
+func (c *ServerConfig) loadEnv() error {
+	durations := []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"RECONNECT_GRACE", &c.ReconnectGrace},
+		{"PING_INTERVAL", &c.Conn.PingInterval},
+		{"PONG_WAIT", &c.Conn.PongWait},
+		{"WRITE_WAIT", &c.Conn.WriteWait},
+		{"SHUTDOWN_TIMEOUT", &c.ShutdownTimeout},
+	}
+	for _, d := range durations {
+		value := os.Getenv(d.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("invalid %s %q: want a positive duration such as 30s", d.name, value)
+		}
+		*d.dst = parsed
+	}
+	if value := os.Getenv("SEND_QUEUE"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid SEND_QUEUE %q: want a positive number", value)
+		}
+		c.Conn.SendQueue = n
+	}
+	if c.Conn.PingInterval >= c.Conn.PongWait {
+		return fmt.Errorf("PING_INTERVAL (%s) must be shorter than PONG_WAIT (%s)", c.Conn.PingInterval, c.Conn.PongWait)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+// queueOnly returns a client with no connection or writer, so tests can
+// watch its outbound queue fill up.
+func queueOnly(size int) *Client {
+	return &Client{
+		PlayerID: "test-player",
+		cfg:      ConnConfig{SendQueue: size},
+		wake:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+	}
+}
+
+func TestRefusedStateFrameNeedsKeyframe(t *testing.T) {
+	tests := []struct {
+		name   string
+		queued func(c *Client)
+	}{
+		{"queued state frames dropped", func(c *Client) {
+			c.Send("welcome")
+			c.SendState("delta")
+		}},
+		{"queue full of messages", func(c *Client) {
+			c.Send("welcome")
+			c.Send("error")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := queueOnly(2)
+			tt.queued(c)
+			if c.NeedsKeyframe() {
+				t.Fatal("keyframe needed before anything was refused")
+			}
+			if c.SendState("delta") {
+				t.Fatal("state frame queued past the limit")
+			}
+			if !c.NeedsKeyframe() {
+				t.Error("refused state frame did not ask for a keyframe")
+			}
+			if c.NeedsKeyframe() {
+				t.Error("keyframe asked for twice")
+			}
+		})
+	}
+}
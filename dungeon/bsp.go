@@ -0,0 +1,76 @@
+package dungeon
+
+import "math/rand"
+
+// BSPRooms splits the map into a binary tree of partitions, digs one
+// rectangular room in every leaf and joins sibling partitions with corridors.
+type BSPRooms struct {
+	// MinLeaf is the smallest width or height a partition may be split into.
+	MinLeaf int
+	// MinRoom is the smallest width or height of a room.
+	MinRoom int
+}
+
+type rect struct{ x, y, w, h int }
+
+func (g BSPRooms) Generate(spec Spec, random *rand.Rand) *Level {
+	width, height := spec.Width, spec.Height
+	tiles := newTiles(width, height)
+	g.split(tiles, rect{x: 1, y: 1, w: width - 2, h: height - 2}, random)
+	return placeFeatures(tiles, spec, random)
+}
+
+// split carves the partition r and returns a floor tile inside it that
+// corridors from neighbouring partitions can connect to.
+func (g BSPRooms) split(tiles [][]int, r rect, random *rand.Rand) Point {
+	canSplitX := r.w >= 2*g.MinLeaf
+	canSplitY := r.h >= 2*g.MinLeaf
+	if !canSplitX && !canSplitY {
+		return g.carveRoom(tiles, r, random)
+	}
+	splitX := canSplitX
+	if canSplitX && canSplitY {
+		switch {
+		case r.w > r.h:
+			splitX = true
+		case r.h > r.w:
+			splitX = false
+		default:
+			splitX = random.Intn(2) == 0
+		}
+	}
+	var a, b rect
+	if splitX {
+		cut := g.MinLeaf + random.Intn(r.w-2*g.MinLeaf+1)
+		a = rect{x: r.x, y: r.y, w: cut, h: r.h}
+		b = rect{x: r.x + cut, y: r.y, w: r.w - cut, h: r.h}
+	} else {
+		cut := g.MinLeaf + random.Intn(r.h-2*g.MinLeaf+1)
+		a = rect{x: r.x, y: r.y, w: r.w, h: cut}
+		b = rect{x: r.x, y: r.y + cut, w: r.w, h: r.h - cut}
+	}
+	pa := g.split(tiles, a, random)
+	pb := g.split(tiles, b, random)
+	carveCorridor(tiles, pa, pb)
+	if random.Intn(2) == 0 {
+		return pa
+	}
+	return pb
+}
+
+// carveRoom digs a room inside r, keeping a wall between it and the edge of
+// the partition, and returns the room's centre.
+func (g BSPRooms) carveRoom(tiles [][]int, r rect, random *rand.Rand) Point {
+	maxW := max(g.MinRoom, r.w-2)
+	maxH := max(g.MinRoom, r.h-2)
+	roomW := g.MinRoom + random.Intn(maxW-g.MinRoom+1)
+	roomH := g.MinRoom + random.Intn(maxH-g.MinRoom+1)
+	roomX := r.x + 1 + random.Intn(max(1, r.w-roomW-1))
+	roomY := r.y + 1 + random.Intn(max(1, r.h-roomH-1))
+	for y := roomY; y < roomY+roomH && y < len(tiles)-1; y++ {
+		for x := roomX; x < roomX+roomW && x < len(tiles[y])-1; x++ {
+			tiles[y][x] = TileFloor
+		}
+	}
+	return Point{X: roomX + roomW/2, Y: roomY + roomH/2}
+}
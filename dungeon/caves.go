@@ -0,0 +1,115 @@
+package dungeon
+
+import "math/rand"
+
+// CellularCaves fills the map with random noise and smooths it with a
+// cellular automaton into organic caves. Only the largest cave is kept so the
+// level is always connected.
+type CellularCaves struct {
+	// FillPercent is the chance, out of 100, that a cell starts as wall.
+	FillPercent int
+	Iterations  int
+}
+
+func (g CellularCaves) Generate(spec Spec, random *rand.Rand) *Level {
+	width, height := spec.Width, spec.Height
+	tiles := newTiles(width, height)
+	for y := 1; y < height-1; y++ {
+		for x := 1; x < width-1; x++ {
+			if random.Intn(100) >= g.FillPercent {
+				tiles[y][x] = TileFloor
+			}
+		}
+	}
+	for i := 0; i < g.Iterations; i++ {
+		tiles = smoothCaves(tiles)
+	}
+	regions := floodRegions(tiles)
+	largest := 0
+	for i, region := range regions {
+		if len(region) > len(regions[largest]) {
+			largest = i
+		}
+	}
+	for i, region := range regions {
+		if i == largest {
+			continue
+		}
+		for _, p := range region {
+			tiles[p.Y][p.X] = TileWall
+		}
+	}
+	return placeFeatures(tiles, spec, random)
+}
+
+// smoothCaves runs one automaton step: a cell becomes wall when five or more
+// of the nine cells around and including it are walls. Cells outside the map
+// count as walls and the border always stays solid.
+func smoothCaves(tiles [][]int) [][]int {
+	height := len(tiles)
+	width := len(tiles[0])
+	next := newTiles(width, height)
+	for y := 1; y < height-1; y++ {
+		for x := 1; x < width-1; x++ {
+			walls := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if tiles[y+dy][x+dx] == TileWall {
+						walls++
+					}
+				}
+			}
+			if walls < 5 {
+				next[y][x] = TileFloor
+			}
+		}
+	}
+	return next
+}
+
+// floodRegions groups every non-wall tile into 4-connected regions.
+func floodRegions(tiles [][]int) [][]Point {
+	height := len(tiles)
+	width := len(tiles[0])
+	seen := make([][]bool, height)
+	for y := range seen {
+		seen[y] = make([]bool, width)
+	}
+	var regions [][]Point
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if seen[y][x] || tiles[y][x] == TileWall {
+				continue
+			}
+			var region []Point
+			stack := []Point{{X: x, Y: y}}
+			seen[y][x] = true
+			for len(stack) > 0 {
+				p := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				region = append(region, p)
+				for _, n := range neighbours(p) {
+					if n.X < 0 || n.X >= width || n.Y < 0 || n.Y >= height {
+						continue
+					}
+					if seen[n.Y][n.X] || tiles[n.Y][n.X] == TileWall {
+						continue
+					}
+					seen[n.Y][n.X] = true
+					stack = append(stack, n)
+				}
+			}
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+func neighbours(p Point) [4]Point {
+	return [4]Point{
+		{X: p.X, Y: p.Y - 1},
+		{X: p.X, Y: p.Y + 1},
+		{X: p.X - 1, Y: p.Y},
+		{X: p.X + 1, Y: p.Y},
+	}
+}
@@ -2,7 +2,7 @@ package dungeon
 
 import (
 	"math/rand"
-	"time"
+	"sort"
 )
 
 const (
@@ -27,107 +27,130 @@ const (
 
 type Point struct{ X, Y int }
 
-func GenerateDungeon(width, height int) ([][]int, []Point, Point, Point, map[Point]string) {
-	source := rand.NewSource(time.Now().UnixNano())
-	random := rand.New(source)
-	dungeon := make([][]int, height)
+// Level is everything a generator produces for one floor.
+type Level struct {
+	Tiles     [][]int
+	Spawn     Point
+	Exit      Point
+	Fountains []Point
+	// Items maps a floor tile to the ItemTemplates key placed on it.
+	Items map[Point]string
+	// Open holds the floor tiles left free once the exit, spawn, fountains
+	// and items have been placed. Monsters are spawned from it.
+	Open    []Point
+	Metrics Metrics
+}
+
+// Spec describes the level a generator should build: its size and how many
+// fountains and items to place on it.
+type Spec struct {
+	Width, Height int
+	Fountains     int
+	// Items maps an ItemTemplates key to how many copies to place.
+	Items map[string]int
+}
+
+// Generator builds a level. Implementations must take all their randomness
+// from random so a seed always yields the same level.
+type Generator interface {
+	Generate(spec Spec, random *rand.Rand) *Level
+}
+
+// DefaultGenerator is used when a room is created without choosing one.
+const DefaultGenerator = "walk"
+
+// Generators lists the map generators a room can be created with.
+var Generators = map[string]Generator{
+	"walk":  DrunkardWalk{Walkers: 23, Steps: 180},
+	"bsp":   BSPRooms{MinLeaf: 10, MinRoom: 4},
+	"caves": CellularCaves{FillPercent: 45, Iterations: 5},
+}
+
+// LookupGenerator returns the generator registered under name, falling back
+// to DefaultGenerator for an empty name.
+func LookupGenerator(name string) (Generator, bool) {
+	if name == "" {
+		name = DefaultGenerator
+	}
+	g, ok := Generators[name]
+	return g, ok
+}
+
+func newTiles(width, height int) [][]int {
+	tiles := make([][]int, height)
 	for y := 0; y < height; y++ {
-		dungeon[y] = make([]int, width)
+		tiles[y] = make([]int, width)
 		for x := 0; x < width; x++ {
-			dungeon[y][x] = TileWall
+			tiles[y][x] = TileWall
 		}
 	}
-	numWalkers := 23
-	walkLength := 180
-	var walkerStartPoints []Point
-	for i := 0; i < numWalkers; i++ {
-		walkerX := random.Intn(width)
-		walkerY := random.Intn(height)
-		walkerStartPoints = append(walkerStartPoints, Point{X: walkerX, Y: walkerY})
-		for j := 0; j < walkLength; j++ {
-			dungeon[walkerY][walkerX] = TileFloor
-			direction := random.Intn(4)
-			switch direction {
-			case 0:
-				if walkerY > 1 {
-					walkerY--
-				}
-			case 1:
-				if walkerY < height-2 {
-					walkerY++
-				}
-			case 2:
-				if walkerX > 1 {
-					walkerX--
-				}
-			case 3:
-				if walkerX < width-2 {
-					walkerX++
-				}
-			}
-		}
-	}
-	for i := 1; i < len(walkerStartPoints); i++ {
-		p1 := walkerStartPoints[i-1]
-		p2 := walkerStartPoints[i]
-		carveCorridor(dungeon, p1, p2)
-	}
+	return tiles
+}
+
+// placeFeatures repairs a carved map, then picks the exit, spawn, fountains
+// and items from its floor tiles and returns the finished level.
+func placeFeatures(tiles [][]int, spec Spec, random *rand.Rand) *Level {
+	repairLevel(tiles)
+	level := &Level{Tiles: tiles, Items: make(map[Point]string)}
 	var floorTiles []Point
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			if dungeon[y][x] == TileFloor {
+	for y := range tiles {
+		for x := range tiles[y] {
+			if tiles[y][x] == TileFloor {
 				floorTiles = append(floorTiles, Point{X: x, Y: y})
 			}
 		}
 	}
-	var startTile, endTile Point
 	if len(floorTiles) > 1 {
 		exitIndex := random.Intn(len(floorTiles))
 		exitTile := floorTiles[exitIndex]
-		dungeon[exitTile.Y][exitTile.X] = TileExit
-		endTile = exitTile
+		tiles[exitTile.Y][exitTile.X] = TileExit
+		level.Exit = exitTile
 		floorTiles = append(floorTiles[:exitIndex], floorTiles[exitIndex+1:]...)
 		startIndex := random.Intn(len(floorTiles))
-		startTile = floorTiles[startIndex]
+		level.Spawn = floorTiles[startIndex]
 		floorTiles = append(floorTiles[:startIndex], floorTiles[startIndex+1:]...)
 	}
-	numFountains := 3
-	for i := 0; i < numFountains && len(floorTiles) > 0; i++ {
+	for i := 0; i < spec.Fountains && len(floorTiles) > 0; i++ {
 		fountainIndex := random.Intn(len(floorTiles))
 		fountainTile := floorTiles[fountainIndex]
-		dungeon[fountainTile.Y][fountainTile.X] = TileHealth
+		tiles[fountainTile.Y][fountainTile.X] = TileHealth
+		level.Fountains = append(level.Fountains, fountainTile)
 		floorTiles = append(floorTiles[:fountainIndex], floorTiles[fountainIndex+1:]...)
 	}
 
-	itemsToPlace := make(map[Point]string)
-	itemsToSpawn := map[string]int{
-		"sword": 3,
-		"bow":   2,
-		"chainmail": 2,
+	itemNames := make([]string, 0, len(spec.Items))
+	for itemName := range spec.Items {
+		itemNames = append(itemNames, itemName)
 	}
-	for itemName, quantity := range itemsToSpawn {
+	sort.Strings(itemNames)
+	for _, itemName := range itemNames {
+		quantity := spec.Items[itemName]
 		for i := 0; i < quantity; i++ {
 			if len(floorTiles) == 0 {
 				break
 			}
 			idx := random.Intn(len(floorTiles))
 			pos := floorTiles[idx]
-			itemsToPlace[pos] = itemName
+			level.Items[pos] = itemName
 			floorTiles = append(floorTiles[:idx], floorTiles[idx+1:]...)
 		}
 	}
-
-	return dungeon, floorTiles, startTile, endTile, itemsToPlace
+	level.Open = floorTiles
+	return level
 }
 
 func carveCorridor(dungeon [][]int, p1, p2 Point) {
 	x1, y1 := p1.X, p1.Y
 	x2, y2 := p2.X, p2.Y
 	for x := min(x1, x2); x <= max(x1, x2); x++ {
-		dungeon[y1][x] = TileFloor
+		if dungeon[y1][x] == TileWall {
+			dungeon[y1][x] = TileFloor
+		}
 	}
 	for y := min(y1, y2); y <= max(y1, y2); y++ {
-		dungeon[y][x2] = TileFloor
+		if dungeon[y][x2] == TileWall {
+			dungeon[y][x2] = TileFloor
+		}
 	}
 }
 func min(a, b int) int {
@@ -141,4 +164,4 @@ func max(a, b int) int {
 		return a
 	}
 	return b
-}
\ No newline at end of file
+}
@@ -0,0 +1,233 @@
+package dungeon
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// MinFloorRatio is the smallest share of the map that must be walkable for a
+// level to be accepted.
+const MinFloorRatio = 0.2
+
+// maxBuildAttempts bounds how many times Build regenerates a rejected level.
+const maxBuildAttempts = 5
+
+// Metrics describes a generated level. They are computed by Validate and are
+// meant for tests and for tuning generators.
+type Metrics struct {
+	FloorTiles int
+	FloorRatio float64
+	// Regions is the number of separate walkable areas; a valid level has one.
+	Regions int
+	// ExitPathLength is the number of steps from Spawn to Exit, or -1 when
+	// the exit cannot be reached.
+	ExitPathLength int
+	// ChokePoints are walkable tiles whose loss would split the level in two.
+	ChokePoints []Point
+}
+
+// Build runs gen and validates the result, regenerating rejected levels a
+// few times before giving up.
+func Build(gen Generator, spec Spec, random *rand.Rand) (*Level, error) {
+	var err error
+	for attempt := 0; attempt < maxBuildAttempts; attempt++ {
+		level := gen.Generate(spec, random)
+		if level.Metrics, err = Validate(level); err == nil {
+			return level, nil
+		}
+	}
+	return nil, fmt.Errorf("no valid level after %d attempts: %w", maxBuildAttempts, err)
+}
+
+// Validate measures a level and reports why it is unfit to play, if it is.
+func Validate(level *Level) (Metrics, error) {
+	tiles := level.Tiles
+	height := len(tiles)
+	width := len(tiles[0])
+	var m Metrics
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if tiles[y][x] != TileWall {
+				m.FloorTiles++
+			}
+		}
+	}
+	m.FloorRatio = float64(m.FloorTiles) / float64(width*height)
+	m.Regions = len(floodRegions(tiles))
+	m.ExitPathLength = pathLength(tiles, level.Spawn, level.Exit)
+	m.ChokePoints = chokePoints(tiles)
+
+	var problems []error
+	if !hasSolidBorder(tiles) {
+		problems = append(problems, errors.New("outer wall is not solid"))
+	}
+	if m.Regions != 1 {
+		problems = append(problems, fmt.Errorf("level has %d disconnected regions", m.Regions))
+	}
+	if m.ExitPathLength < 0 {
+		problems = append(problems, errors.New("exit is unreachable from spawn"))
+	}
+	if m.FloorRatio < MinFloorRatio {
+		problems = append(problems, fmt.Errorf("floor ratio %.2f is below %.2f", m.FloorRatio, MinFloorRatio))
+	}
+	return m, errors.Join(problems...)
+}
+
+// repairLevel walls off the map edge and joins every walkable region to the
+// largest one so the level is a single connected area. placeFeatures runs it
+// before choosing any feature tiles.
+func repairLevel(tiles [][]int) {
+	height := len(tiles)
+	width := len(tiles[0])
+	for x := 0; x < width; x++ {
+		tiles[0][x] = TileWall
+		tiles[height-1][x] = TileWall
+	}
+	for y := 0; y < height; y++ {
+		tiles[y][0] = TileWall
+		tiles[y][width-1] = TileWall
+	}
+	regions := floodRegions(tiles)
+	if len(regions) < 2 {
+		return
+	}
+	main := 0
+	for i, region := range regions {
+		if len(region) > len(regions[main]) {
+			main = i
+		}
+	}
+	for i, region := range regions {
+		if i == main {
+			continue
+		}
+		from, to := closestPair(region, regions[main])
+		carveCorridor(tiles, from, to)
+	}
+}
+
+// closestPair returns the tiles of a and b nearest to each other.
+func closestPair(a, b []Point) (Point, Point) {
+	best := -1
+	var bestA, bestB Point
+	for _, pa := range a {
+		for _, pb := range b {
+			d := abs(pa.X-pb.X) + abs(pa.Y-pb.Y)
+			if best == -1 || d < best {
+				best, bestA, bestB = d, pa, pb
+			}
+		}
+	}
+	return bestA, bestB
+}
+
+func hasSolidBorder(tiles [][]int) bool {
+	height := len(tiles)
+	width := len(tiles[0])
+	for x := 0; x < width; x++ {
+		if tiles[0][x] != TileWall || tiles[height-1][x] != TileWall {
+			return false
+		}
+	}
+	for y := 0; y < height; y++ {
+		if tiles[y][0] != TileWall || tiles[y][width-1] != TileWall {
+			return false
+		}
+	}
+	return true
+}
+
+// pathLength is the breadth-first walking distance between two tiles, or -1
+// when there is no path.
+func pathLength(tiles [][]int, from, to Point) int {
+	height := len(tiles)
+	width := len(tiles[0])
+	dist := make([][]int, height)
+	for y := range dist {
+		dist[y] = make([]int, width)
+		for x := range dist[y] {
+			dist[y][x] = -1
+		}
+	}
+	if tiles[from.Y][from.X] == TileWall {
+		return -1
+	}
+	dist[from.Y][from.X] = 0
+	queue := []Point{from}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if p == to {
+			return dist[p.Y][p.X]
+		}
+		for _, n := range neighbours(p) {
+			if n.X < 0 || n.X >= width || n.Y < 0 || n.Y >= height {
+				continue
+			}
+			if dist[n.Y][n.X] != -1 || tiles[n.Y][n.X] == TileWall {
+				continue
+			}
+			dist[n.Y][n.X] = dist[p.Y][p.X] + 1
+			queue = append(queue, n)
+		}
+	}
+	return -1
+}
+
+// chokePoints finds the articulation points of the walkable tiles using
+// Tarjan's low-link algorithm.
+func chokePoints(tiles [][]int) []Point {
+	height := len(tiles)
+	width := len(tiles[0])
+	order := make([][]int, height)
+	low := make([][]int, height)
+	for y := range order {
+		order[y] = make([]int, width)
+		low[y] = make([]int, width)
+	}
+	counter := 0
+	var points []Point
+	var visit func(p, parent Point, isRoot bool)
+	visit = func(p, parent Point, isRoot bool) {
+		counter++
+		order[p.Y][p.X] = counter
+		low[p.Y][p.X] = counter
+		children := 0
+		isChoke := false
+		for _, n := range neighbours(p) {
+			if n.X < 0 || n.X >= width || n.Y < 0 || n.Y >= height || tiles[n.Y][n.X] == TileWall {
+				continue
+			}
+			if order[n.Y][n.X] == 0 {
+				children++
+				visit(n, p, false)
+				low[p.Y][p.X] = min(low[p.Y][p.X], low[n.Y][n.X])
+				if !isRoot && low[n.Y][n.X] >= order[p.Y][p.X] {
+					isChoke = true
+				}
+			} else if n != parent {
+				low[p.Y][p.X] = min(low[p.Y][p.X], order[n.Y][n.X])
+			}
+		}
+		if isChoke || (isRoot && children > 1) {
+			points = append(points, p)
+		}
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if tiles[y][x] != TileWall && order[y][x] == 0 {
+				p := Point{X: x, Y: y}
+				visit(p, p, true)
+			}
+		}
+	}
+	return points
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
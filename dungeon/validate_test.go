@@ -0,0 +1,261 @@
+package dungeon
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// grid turns rows of '#' (wall) and '.' (floor) into tiles. 'S' and 'E' are
+// floor tiles returned as the spawn and the exit.
+func grid(rows ...string) (tiles [][]int, spawn, exit Point) {
+	tiles = make([][]int, len(rows))
+	for y, row := range rows {
+		tiles[y] = make([]int, len(row))
+		for x, r := range row {
+			switch r {
+			case '#':
+				tiles[y][x] = TileWall
+			case 'E':
+				tiles[y][x] = TileExit
+				exit = Point{X: x, Y: y}
+			case 'S':
+				tiles[y][x] = TileFloor
+				spawn = Point{X: x, Y: y}
+			default:
+				tiles[y][x] = TileFloor
+			}
+		}
+	}
+	return tiles, spawn, exit
+}
+
+func draw(tiles [][]int) string {
+	var b strings.Builder
+	for _, row := range tiles {
+		for _, tile := range row {
+			if tile == TileWall {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    []string
+		problem string
+	}{
+		{
+			name: "playable",
+			rows: []string{
+				"######",
+				"#S..E#",
+				"#....#",
+				"######",
+			},
+		},
+		{
+			name: "open border",
+			rows: []string{
+				"######",
+				"#S..E.",
+				"#....#",
+				"######",
+			},
+			problem: "outer wall is not solid",
+		},
+		{
+			name: "two regions",
+			rows: []string{
+				"#######",
+				"#S.#..#",
+				"#.E#..#",
+				"#######",
+			},
+			problem: "2 disconnected regions",
+		},
+		{
+			name: "exit walled off",
+			rows: []string{
+				"#######",
+				"#S..#E#",
+				"#...#.#",
+				"#######",
+			},
+			problem: "exit is unreachable from spawn",
+		},
+		{
+			name: "too little floor",
+			rows: []string{
+				"########",
+				"#SE#####",
+				"########",
+				"########",
+			},
+			problem: "floor ratio",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tiles, spawn, exit := grid(tt.rows...)
+			_, err := Validate(&Level{Tiles: tiles, Spawn: spawn, Exit: exit})
+			switch {
+			case tt.problem == "" && err != nil:
+				t.Errorf("unexpected problem: %v", err)
+			case tt.problem != "" && (err == nil || !strings.Contains(err.Error(), tt.problem)):
+				t.Errorf("got %v, want a problem mentioning %q", err, tt.problem)
+			}
+		})
+	}
+}
+
+func TestValidateMetrics(t *testing.T) {
+	tiles, spawn, exit := grid(
+		"#########",
+		"#S..#...#",
+		"#...#...#",
+		"#.......#",
+		"#.....E.#",
+		"#########",
+	)
+	m, err := Validate(&Level{Tiles: tiles, Spawn: spawn, Exit: exit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.FloorTiles != 26 || m.Regions != 1 || m.ExitPathLength != 8 || len(m.ChokePoints) != 0 {
+		t.Errorf("got %+v, want 26 floor tiles, 1 region, exit 8 steps away and no choke points", m)
+	}
+	if want := 26.0 / 54.0; m.FloorRatio != want {
+		t.Errorf("floor ratio %v, want %v", m.FloorRatio, want)
+	}
+}
+
+func TestRepairLevelWallsTheBorder(t *testing.T) {
+	tiles, _, _ := grid(
+		".....",
+		".....",
+		".....",
+	)
+	repairLevel(tiles)
+	want := "#####\n#...#\n#####\n"
+	if got := draw(tiles); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+}
+
+func TestRepairLevelJoinsRegions(t *testing.T) {
+	tiles, _, _ := grid(
+		"##########",
+		"#....#####",
+		"#....#####",
+		"#....###.#",
+		"##########",
+		"#..#######",
+		"##########",
+	)
+	repairLevel(tiles)
+	if regions := floodRegions(tiles); len(regions) != 1 {
+		t.Fatalf("%d regions left:\n%s", len(regions), draw(tiles))
+	}
+	if !hasSolidBorder(tiles) {
+		t.Errorf("border broken by a corridor:\n%s", draw(tiles))
+	}
+	// The largest region is kept as it was; the others are joined to it.
+	for y := 1; y <= 3; y++ {
+		for x := 1; x <= 4; x++ {
+			if tiles[y][x] != TileFloor {
+				t.Fatalf("main region lost tile %v:\n%s", Point{X: x, Y: y}, draw(tiles))
+			}
+		}
+	}
+}
+
+func TestChokePoints(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []string
+		want []Point
+	}{
+		{
+			name: "open room",
+			rows: []string{
+				"#####",
+				"#...#",
+				"#...#",
+				"#####",
+			},
+		},
+		{
+			name: "rooms joined by a corridor",
+			rows: []string{
+				"##########",
+				"#..####..#",
+				"#........#",
+				"#..####..#",
+				"##########",
+			},
+			want: []Point{{2, 2}, {3, 2}, {4, 2}, {5, 2}, {6, 2}, {7, 2}},
+		},
+		{
+			name: "ring",
+			rows: []string{
+				"#####",
+				"#...#",
+				"#.#.#",
+				"#...#",
+				"#####",
+			},
+		},
+		{
+			name: "dead end",
+			rows: []string{
+				"######",
+				"#...##",
+				"#.....",
+				"#...##",
+				"######",
+			},
+			want: []Point{{3, 2}, {4, 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tiles, _, _ := grid(tt.rows...)
+			got := map[Point]bool{}
+			for _, p := range chokePoints(tiles) {
+				got[p] = true
+			}
+			want := map[Point]bool{}
+			for _, p := range tt.want {
+				want[p] = true
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("choke points %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// Every registered generator yields levels that pass validation.
+func TestGeneratorsBuildValidLevels(t *testing.T) {
+	spec := Spec{Width: MapWidth, Height: MapHeight, Fountains: 3, Items: map[string]int{"sword": 2}}
+	for name, gen := range Generators {
+		for seed := int64(1); seed <= 20; seed++ {
+			level, err := Build(gen, spec, rand.New(rand.NewSource(seed)))
+			if err != nil {
+				t.Errorf("%s seed %d: %v", name, seed, err)
+				continue
+			}
+			if level.Tiles[level.Spawn.Y][level.Spawn.X] != TileFloor {
+				t.Errorf("%s seed %d: spawn %v is not floor", name, seed, level.Spawn)
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package dungeon
+
+import "math/rand"
+
+// DrunkardWalk carves the map with a number of random walkers and then links
+// each walker's starting point to the next one with an L-shaped corridor.
+type DrunkardWalk struct {
+	Walkers int
+	Steps   int
+}
+
+func (g DrunkardWalk) Generate(spec Spec, random *rand.Rand) *Level {
+	width, height := spec.Width, spec.Height
+	dungeon := newTiles(width, height)
+	var walkerStartPoints []Point
+	for i := 0; i < g.Walkers; i++ {
+		walkerX := 1 + random.Intn(width-2)
+		walkerY := 1 + random.Intn(height-2)
+		walkerStartPoints = append(walkerStartPoints, Point{X: walkerX, Y: walkerY})
+		for j := 0; j < g.Steps; j++ {
+			dungeon[walkerY][walkerX] = TileFloor
+			direction := random.Intn(4)
+			switch direction {
+			case 0:
+				if walkerY > 1 {
+					walkerY--
+				}
+			case 1:
+				if walkerY < height-2 {
+					walkerY++
+				}
+			case 2:
+				if walkerX > 1 {
+					walkerX--
+				}
+			case 3:
+				if walkerX < width-2 {
+					walkerX++
+				}
+			}
+		}
+	}
+	for i := 1; i < len(walkerStartPoints); i++ {
+		p1 := walkerStartPoints[i-1]
+		p2 := walkerStartPoints[i]
+		carveCorridor(dungeon, p1, p2)
+	}
+	return placeFeatures(dungeon, spec, random)
+}
@@ -0,0 +1,213 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"fmt"
+	"sort"
+)
+
+// Class abilities. Each class has one, used with the "ability" command.
+const (
+	AbilityShieldBash     = "shieldBash"
+	AbilityMultiShot      = "multiShot"
+	AbilityExtendedVision = "extendedVision"
+	AbilityHealAlly       = "healAlly"
+)
+
+// abilityCooldowns is how many of the player's turns, counting the one it was
+// used on, pass before an ability can be used again.
+var abilityCooldowns = map[string]int{
+	AbilityShieldBash:     3,
+	AbilityMultiShot:      5,
+	AbilityExtendedVision: 15,
+	AbilityHealAlly:       6,
+}
+
+const (
+	shieldBashBonus  = 5
+	multiShotTargets = 3
+	visionBoost      = 4
+	visionBoostTurns = 10
+	healAllyAmount   = 25
+	healAllyRange    = 3
+)
+
+// Class is a character class a player can pick when joining: base stats,
+// starting gear and an ability.
+type Class struct {
+	Name  string
+	Stats PlayerStats
+	// Gear lists the ItemTemplates keys the class starts with. The first
+	// weapon and armor are equipped.
+	Gear    []string
+	Ability string
+}
+
+// Classes holds every class by key. It is loaded from the built-in content
+// pack; see LoadContentDir. Players who pick no class start with PlayerBase
+// and no ability.
+var Classes map[string]Class
+
+// ValidClass reports whether key names a class or is empty.
+func ValidClass(key string) bool {
+	if key == "" {
+		return true
+	}
+	_, ok := Classes[key]
+	return ok
+}
+
+// classStats returns the base stats of the class with the given key, or
+// PlayerBase for none.
+func classStats(key string) PlayerStats {
+	if c, ok := Classes[key]; ok {
+		return c.Stats
+	}
+	return PlayerBase
+}
+
+// endTurn counts down the player's ability cooldown and any timed effects.
+// It runs after every command that let the monsters act.
+func (p *Player) endTurn() {
+	if p.AbilityCooldown > 0 {
+		p.AbilityCooldown--
+	}
+	if p.VisionTurns > 0 {
+		p.VisionTurns--
+		if p.VisionTurns == 0 {
+			p.VisionBonus = 0
+		}
+	}
+}
+
+// useAbility performs the player's class ability. Like ProcessPlayerCommand
+// it returns true when no turn was spent.
+func useAbility(player *Player, cmd Command, state *GameState) bool {
+	class, ok := Classes[player.Class]
+	if !ok || class.Ability == "" {
+		player.addMessage("You have no class ability.")
+		return true
+	}
+	if player.AbilityCooldown > 0 {
+		player.addMessage(fmt.Sprintf("Your ability is ready in %d turns.", player.AbilityCooldown))
+		return true
+	}
+	var used bool
+	switch class.Ability {
+	case AbilityShieldBash:
+		used = shieldBash(player, cmd, state)
+	case AbilityMultiShot:
+		used = multiShot(player, state)
+	case AbilityExtendedVision:
+		player.VisionBonus, player.VisionTurns = visionBoost, visionBoostTurns
+		state.Emit(Event{Type: EventAbilityUsed, Source: player.actor(), Ability: class.Ability})
+		used = true
+	case AbilityHealAlly:
+		used = healAlly(player, cmd, state)
+	}
+	if !used {
+		return true
+	}
+	player.AbilityCooldown = abilityCooldowns[class.Ability]
+	return false
+}
+
+// shieldBash hits an adjacent monster harder than a normal attack and knocks
+// it back a tile. It does not draw a counter-attack. The monster is the one
+// in cmd.Dir, at cmd.Target, or else the first one found next to the player.
+func shieldBash(player *Player, cmd Command, state *GameState) bool {
+	var target *Monster
+	switch {
+	case cmd.Dir != "":
+		step := Directions[cmd.Dir]
+		pos := dungeon.Point{X: player.Position.X + step.X, Y: player.Position.Y + step.Y}
+		target, _ = FindMonsterAt(state, &pos)
+	case cmd.Target != nil:
+		if Distance(player.Position, *cmd.Target) == 1 {
+			target, _ = FindMonsterAt(state, cmd.Target)
+		}
+	default:
+		for _, m := range state.Monsters {
+			if Distance(player.Position, m.Position) == 1 && m.CurrentHP > 0 {
+				target = m
+				break
+			}
+		}
+	}
+	if target == nil {
+		player.addMessage("There is nothing next to you to bash.")
+		return false
+	}
+	state.Emit(Event{Type: EventAbilityUsed, Source: player.actor(), Target: target.actor(), Ability: AbilityShieldBash})
+	if !Resolve(state, player, target, AttackMelee, Modifiers{Bonus: shieldBashBonus}).Killed {
+		target.Move(target.Position.X-player.Position.X, target.Position.Y-player.Position.Y, state)
+	}
+	return true
+}
+
+// multiShot fires the equipped ranged weapon at the closest monsters in
+// range, one arrow each.
+func multiShot(player *Player, state *GameState) bool {
+	if player.EquippedWeapon == nil || player.EquippedWeapon.Range <= 1 {
+		player.addMessage("You don't have a ranged weapon equipped!")
+		return false
+	}
+	var targets []*Monster
+	for _, m := range state.Monsters {
+		if CanShoot(state, player, m) {
+			targets = append(targets, m)
+		}
+	}
+	if len(targets) == 0 {
+		player.addMessage("No valid targets in sight.")
+		return false
+	}
+	sort.SliceStable(targets, func(i, j int) bool {
+		return Distance(player.Position, targets[i].Position) < Distance(player.Position, targets[j].Position)
+	})
+	if len(targets) > multiShotTargets {
+		targets = targets[:multiShotTargets]
+	}
+	state.Emit(Event{Type: EventAbilityUsed, Source: player.actor(), Ability: AbilityMultiShot})
+	for _, m := range targets {
+		Resolve(state, player, m, AttackRanged, Modifiers{})
+	}
+	return true
+}
+
+// healAlly restores HP to the ally at cmd.Target, or else to the most
+// wounded ally in range and in sight. Defeated players cannot be healed.
+func healAlly(player *Player, cmd Command, state *GameState) bool {
+	var target *Player
+	bestShare := 1.0
+	for _, id := range state.PlayerIDs() {
+		ally := state.Players[id]
+		if ally == player || ally.Status == "defeated" || ally.HP >= ally.MaxHP {
+			continue
+		}
+		if Distance(player.Position, ally.Position) > healAllyRange || !CanSee(state.Dungeon, player.Position, ally.Position, healAllyRange) {
+			continue
+		}
+		if cmd.Target != nil {
+			if ally.Position == *cmd.Target {
+				target = ally
+				break
+			}
+			continue
+		}
+		if share := float64(ally.HP) / float64(ally.MaxHP); share < bestShare {
+			target, bestShare = ally, share
+		}
+	}
+	if target == nil {
+		player.addMessage("No wounded ally is close enough to heal.")
+		return false
+	}
+	before := target.HP
+	target.HP += healAllyAmount
+	if target.HP > target.MaxHP {
+		target.HP = target.MaxHP
+	}
+	state.Emit(Event{Type: EventAbilityUsed, Source: player.actor(), Target: target.actor(), Ability: AbilityHealAlly, Amount: target.HP - before})
+	return true
+}
@@ -0,0 +1,148 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"testing"
+)
+
+func ability(dir string, target *dungeon.Point) Command {
+	return Command{Version: 1, Type: CmdAbility, Dir: dir, Target: target}
+}
+
+func TestShieldBashKnockback(t *testing.T) {
+	for dir, step := range Directions {
+		t.Run(dir, func(t *testing.T) {
+			gs, marks := arena(t, "#######", "#.....#", "#.....#", "#..@..#", "#.....#", "#.....#", "#######")
+			p := join(gs, "alice-1", "warrior", marks['@'][0])
+			from := dungeon.Point{X: p.Position.X + step.X, Y: p.Position.Y + step.Y}
+			m := spawn(gs, from, 100, 6, 10)
+			if _, early := ProcessPlayerCommand(p.ID, ability(dir, nil), gs); early {
+				t.Fatal("shield bash took no turn")
+			}
+			want := dungeon.Point{X: from.X + step.X, Y: from.Y + step.Y}
+			if m.Position != want {
+				t.Errorf("monster knocked to %v, want %v", m.Position, want)
+			}
+			if hurt := 100 - m.CurrentHP; hurt != p.AttackPower()+shieldBashBonus {
+				t.Errorf("bash dealt %d damage, want %d", hurt, p.AttackPower()+shieldBashBonus)
+			}
+		})
+	}
+}
+
+// A monster backed against a wall takes the hit but stays put.
+func TestShieldBashAgainstWall(t *testing.T) {
+	gs, marks := arena(t, "#####", "#@g#", "#####")
+	p := join(gs, "alice-1", "warrior", marks['@'][0])
+	m := spawn(gs, marks['g'][0], 100, 6, 10)
+	ProcessPlayerCommand(p.ID, ability("east", nil), gs)
+	if m.Position != marks['g'][0] || m.CurrentHP == 100 {
+		t.Errorf("monster at %v with %d HP", m.Position, m.CurrentHP)
+	}
+}
+
+// Multi-shot hits the three closest monsters the ranger can shoot, skipping
+// closer ones behind walls.
+func TestMultiShotTargets(t *testing.T) {
+	gs, marks := arena(t,
+		"##########",
+		"#@abc.d..#",
+		"##########",
+		"#x.......#",
+	)
+	p := join(gs, "alice-1", "ranger", marks['@'][0])
+	hp := map[rune]*Monster{}
+	for _, r := range "abcdx" {
+		hp[r] = spawn(gs, marks[r][0], 100, 6, 10)
+	}
+	if _, early := ProcessPlayerCommand(p.ID, ability("", nil), gs); early {
+		t.Fatal("multi-shot took no turn")
+	}
+	for r, m := range hp {
+		hit := m.CurrentHP < 100
+		if want := r == 'a' || r == 'b' || r == 'c'; hit != want {
+			t.Errorf("monster %c hit: %v, want %v", r, hit, want)
+		}
+	}
+}
+
+// The boost, like a cooldown, counts the turn it was used on.
+func TestExtendedVision(t *testing.T) {
+	gs, marks := arena(t, "########", "#@.....#", "########")
+	p := join(gs, "alice-1", "scout", marks['@'][0])
+	base := gs.EffectiveVision(p)
+	gs.Step(ClientCommand{PlayerID: p.ID, Command: ability("", nil)})
+	for turn := 2; turn <= visionBoostTurns; turn++ {
+		if got := gs.EffectiveVision(p); got != base+visionBoost {
+			t.Fatalf("vision %d on turn %d of the boost, want %d", got, turn, base+visionBoost)
+		}
+		dir := "east"
+		if turn%2 == 0 {
+			dir = "west"
+		}
+		gs.Step(ClientCommand{PlayerID: p.ID, Command: Command{Version: 1, Type: CmdMove, Dir: dir}})
+	}
+	if got := gs.EffectiveVision(p); got != base {
+		t.Errorf("vision %d after the boost, want %d", got, base)
+	}
+}
+
+func TestHealAllyRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   []string
+		healed bool
+	}{
+		{"adjacent", []string{"#######", "#@a...#", "#######"}, true},
+		{"at range", []string{"#######", "#@..a.#", "#######"}, true},
+		{"too far", []string{"#######", "#@...a#", "#######"}, false},
+		{"behind a wall", []string{"#######", "#@#a..#", "#######"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs, marks := arena(t, tt.rows...)
+			p := join(gs, "alice-1", "cleric", marks['@'][0])
+			ally := join(gs, "bobby-2", "warrior", marks['a'][0])
+			ally.HP = 50
+			_, early := ProcessPlayerCommand(p.ID, ability("", nil), gs)
+			if healed := ally.HP > 50; healed != tt.healed {
+				t.Fatalf("ally healed: %v, want %v", healed, tt.healed)
+			}
+			if tt.healed && (early || ally.HP != 50+healAllyAmount) {
+				t.Errorf("ally at %d HP, turn spent: %v", ally.HP, !early)
+			}
+			if !tt.healed && (!early || p.AbilityCooldown != 0) {
+				t.Errorf("failed heal spent the turn or started the cooldown")
+			}
+		})
+	}
+}
+
+func TestAbilityCooldown(t *testing.T) {
+	gs, marks := arena(t, "#######", "#.....#", "#@g...#", "#######")
+	p := join(gs, "alice-1", "warrior", marks['@'][0])
+	m := spawn(gs, marks['g'][0], 1000, 0, 10)
+	gs.Step(ClientCommand{PlayerID: p.ID, Command: ability("east", nil)})
+	wait := abilityCooldowns[AbilityShieldBash]
+	for turn := wait - 1; turn > 0; turn-- {
+		if p.AbilityCooldown != turn {
+			t.Fatalf("cooldown %d, want %d", p.AbilityCooldown, turn)
+		}
+		hp := m.CurrentHP
+		if _, early := ProcessPlayerCommand(p.ID, ability("", nil), gs); !early || m.CurrentHP != hp {
+			t.Fatalf("ability used with %d turns of cooldown left", turn)
+		}
+		dir := "north"
+		if p.Position.Y == 1 {
+			dir = "south"
+		}
+		gs.Step(ClientCommand{PlayerID: p.ID, Command: Command{Version: 1, Type: CmdMove, Dir: dir}})
+	}
+	if p.AbilityCooldown != 0 {
+		t.Fatalf("cooldown %d after %d turns", p.AbilityCooldown, wait)
+	}
+	p.Position = dungeon.Point{X: m.Position.X - 1, Y: m.Position.Y}
+	if _, early := ProcessPlayerCommand(p.ID, ability("east", nil), gs); early {
+		t.Error("ability still cooling down")
+	}
+}
@@ -0,0 +1,147 @@
+package game
+
+// AttackKind says how an attack is delivered. It decides how the attack is
+// reported; the damage rules are the same for every kind.
+type AttackKind string
+
+const (
+	AttackMelee  AttackKind = "melee"
+	AttackRanged AttackKind = "ranged"
+	// AttackSmite is a monster's magical strike from afar, such as the
+	// Guardian's.
+	AttackSmite AttackKind = "smite"
+	// AttackBlast is a thrown bomb.
+	AttackBlast AttackKind = "blast"
+)
+
+// Combatant is anything that can deal and take damage: players and monsters.
+type Combatant interface {
+	// CombatName is how the combatant is named in the log.
+	CombatName() string
+	// AttackPower is the damage an unmodified attack deals.
+	AttackPower() int
+	isPlayer() bool
+	actor() *Actor
+	down() bool
+	armor() *Item
+	breakArmor()
+	takeDamage(damage int)
+}
+
+// Modifiers adjust a single attack.
+type Modifiers struct {
+	// Damage, when positive, replaces the attacker's own attack power.
+	Damage int
+	// Bonus is added to the attacker's damage.
+	Bonus int
+	// IgnoreArmor sends the damage straight to HP.
+	IgnoreArmor bool
+}
+
+// CombatResult describes what one attack did.
+type CombatResult struct {
+	Kind AttackKind
+	// Hit is false when the defender was already down.
+	Hit bool
+	// Damage is what the defender lost in HP.
+	Damage int
+	// Absorbed is what the defender's armor took instead.
+	Absorbed int
+	// Broken is the armor that broke, if any.
+	Broken *Item
+	// Killed is set when the attack defeated the defender.
+	Killed bool
+}
+
+// Resolve carries out an attack and emits its events. Armor takes the whole
+// hit while it lasts and breaks once its durability runs out. A player who
+// kills a monster earns its XP.
+func Resolve(state *GameState, attacker, defender Combatant, kind AttackKind, mods Modifiers) CombatResult {
+	result := CombatResult{Kind: kind}
+	if defender.down() {
+		return result
+	}
+	result.Hit = true
+	damage := attacker.AttackPower()
+	if mods.Damage > 0 {
+		damage = mods.Damage
+	}
+	damage += mods.Bonus
+	if damage < 0 {
+		damage = 0
+	}
+	source := attacker.actor()
+	state.Emit(Event{Type: EventAttacked, Source: source, Target: defender.actor(), Kind: kind})
+	if armor := defender.armor(); armor != nil && !mods.IgnoreArmor {
+		armor.Durability -= damage
+		result.Absorbed = damage
+		state.Emit(Event{Type: EventDamaged, Source: source, Target: defender.actor(), Kind: kind, Absorbed: damage})
+		if armor.Durability <= 0 {
+			result.Broken = armor
+			defender.breakArmor()
+			state.Emit(Event{Type: EventArmorBroke, Target: defender.actor(), Item: armor.Name})
+		}
+		return result
+	}
+	result.Damage = damage
+	defender.takeDamage(damage)
+	state.Emit(Event{Type: EventDamaged, Source: source, Target: defender.actor(), Kind: kind, Amount: damage})
+	if defender.down() {
+		result.Killed = true
+		killed := EventMonsterKilled
+		if defender.isPlayer() {
+			killed = EventPlayerDefeated
+		}
+		state.Emit(Event{Type: killed, Source: source, Target: defender.actor()})
+		killer, byPlayer := attacker.(*Player)
+		monster, ofMonster := defender.(*Monster)
+		if byPlayer && ofMonster {
+			state.awardXP(killer, monster)
+		}
+	}
+	return result
+}
+
+func (p *Player) CombatName() string { return p.ID[0:4] }
+
+// AttackPower is the player's own attack when unarmed. Armed, it is the
+// equipped weapon's damage plus the attack gained from levels.
+func (p *Player) AttackPower() int {
+	if p.EquippedWeapon != nil {
+		return p.EquippedWeapon.Damage + p.levelAttack()
+	}
+	return p.Attack
+}
+
+// levelAttack is the attack the player has gained from levels: what gainXP
+// added on top of their class's base attack.
+func (p *Player) levelAttack() int {
+	bonus := p.Attack - classStats(p.Class).Attack
+	if bonus < 0 {
+		return 0
+	}
+	return bonus
+}
+
+func (p *Player) isPlayer() bool { return true }
+func (p *Player) down() bool     { return p.Status == "defeated" }
+func (p *Player) armor() *Item   { return p.EquippedArmor }
+func (p *Player) breakArmor()    { p.removeItem(p.EquippedArmor) }
+
+func (p *Player) takeDamage(damage int) {
+	p.HP -= damage
+	if p.HP <= 0 {
+		p.Status = "defeated"
+	}
+}
+
+func (m *Monster) CombatName() string { return m.Template.Name }
+func (m *Monster) AttackPower() int   { return m.Template.Attack }
+func (m *Monster) isPlayer() bool     { return false }
+func (m *Monster) down() bool         { return m.CurrentHP <= 0 }
+func (m *Monster) armor() *Item       { return nil }
+func (m *Monster) breakArmor()        {}
+
+func (m *Monster) takeDamage(damage int) {
+	m.CurrentHP -= damage
+}
@@ -1,7 +1,111 @@
 package game
 
+import (
+	"bytes"
+	"dunExpo/dungeon"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ProtocolVersion is the command envelope version this server understands.
+// Clients send it as "v" on every command.
+const ProtocolVersion = 1
+
+// Command types a client may send.
+const (
+	CmdMove   = "move"
+	CmdAttack = "attack"
+	CmdPickup = "pickup"
+	CmdEquip  = "equip"
+	CmdDrop   = "drop"
+	CmdAim    = "aim"
+	CmdFire   = "fire"
+	CmdCancel = "cancel"
+	// CmdAbility uses the player's class ability. Dir or Target pick who
+	// it is aimed at where that matters.
+	CmdAbility = "ability"
+	// CmdUse uses one of a consumable item, on the player or on the ally or
+	// tile at Target.
+	CmdUse = "use"
+	// CmdResync asks the server for a full state keyframe. It is not a game
+	// action and takes no turn.
+	CmdResync = "resync"
+)
+
+// Commands queued by the server itself; clients cannot send them. CmdQuit
+// follows a closed connection, CmdExpire the end of a disconnected player's
+// reconnect grace period and CmdShutdown a server shutdown.
+const (
+	CmdQuit     = "quit"
+	CmdExpire   = "expire"
+	CmdShutdown = "shutdown"
+)
+
+// Directions maps the "dir" of a move command to a step on the map.
+var Directions = map[string]dungeon.Point{
+	"north": {X: 0, Y: -1},
+	"south": {X: 0, Y: 1},
+	"west":  {X: -1, Y: 0},
+	"east":  {X: 1, Y: 0},
+}
+
+// Command is the typed envelope for a player action, for example
+// {"v":1,"type":"move","dir":"north"} or {"v":1,"type":"equip","item":"Bow"}.
+type Command struct {
+	Version int            `json:"v"`
+	Type    string         `json:"type"`
+	Dir     string         `json:"dir,omitempty"`
+	Target  *dungeon.Point `json:"target,omitempty"`
+	Item    string         `json:"item,omitempty"`
+}
 
 type ClientCommand struct {
 	PlayerID string
-	Command  string
-}
\ No newline at end of file
+	Command  Command
+}
+
+// ParseCommand decodes and validates a command frame from a client.
+func ParseCommand(data []byte) (Command, error) {
+	var cmd Command
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cmd); err != nil {
+		return Command{}, fmt.Errorf("malformed command: %v", err)
+	}
+	if err := cmd.Validate(); err != nil {
+		return Command{}, err
+	}
+	return cmd, nil
+}
+
+// Validate checks the version and the fields each command type requires.
+func (c Command) Validate() error {
+	if c.Version != ProtocolVersion {
+		return fmt.Errorf("unsupported protocol version %d, expected %d", c.Version, ProtocolVersion)
+	}
+	switch c.Type {
+	case CmdMove:
+		if _, ok := Directions[c.Dir]; !ok {
+			return fmt.Errorf("move: dir must be north, south, east or west, not %q", c.Dir)
+		}
+	case CmdAttack:
+		if c.Target == nil {
+			return errors.New("attack: target is required")
+		}
+	case CmdUse:
+		if c.Item == "" {
+			return errors.New("use: item is required")
+		}
+	case CmdAbility:
+		if _, ok := Directions[c.Dir]; c.Dir != "" && !ok {
+			return fmt.Errorf("ability: dir must be north, south, east or west, not %q", c.Dir)
+		}
+	case CmdPickup, CmdEquip, CmdDrop, CmdAim, CmdFire, CmdCancel, CmdResync:
+	case "":
+		return errors.New("command type is required")
+	default:
+		return fmt.Errorf("unknown command type %q", c.Type)
+	}
+	return nil
+}
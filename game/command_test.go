@@ -0,0 +1,109 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"reflect"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		frame   string
+		want    Command
+		wantErr string
+	}{
+		{
+			name:  "move",
+			frame: `{"v":1,"type":"move","dir":"north"}`,
+			want:  Command{Version: 1, Type: CmdMove, Dir: "north"},
+		},
+		{
+			name:  "attack",
+			frame: `{"v":1,"type":"attack","target":{"X":10,"Y":4}}`,
+			want:  Command{Version: 1, Type: CmdAttack, Target: &dungeon.Point{X: 10, Y: 4}},
+		},
+		{
+			name:  "ability without a direction",
+			frame: `{"v":1,"type":"ability"}`,
+			want:  Command{Version: 1, Type: CmdAbility},
+		},
+		{
+			name:    "missing version",
+			frame:   `{"type":"move","dir":"north"}`,
+			wantErr: "unsupported protocol version 0, expected 1",
+		},
+		{
+			name:    "future version",
+			frame:   `{"v":2,"type":"move","dir":"north"}`,
+			wantErr: "unsupported protocol version 2, expected 1",
+		},
+		{
+			name:    "missing type",
+			frame:   `{"v":1}`,
+			wantErr: "command type is required",
+		},
+		{
+			name:    "unknown type",
+			frame:   `{"v":1,"type":"dance"}`,
+			wantErr: `unknown command type "dance"`,
+		},
+		{
+			name:    "server-only type",
+			frame:   `{"v":1,"type":"quit"}`,
+			wantErr: `unknown command type "quit"`,
+		},
+		{
+			name:    "move without a direction",
+			frame:   `{"v":1,"type":"move"}`,
+			wantErr: `move: dir must be north, south, east or west, not ""`,
+		},
+		{
+			name:    "bad move direction",
+			frame:   `{"v":1,"type":"move","dir":"up"}`,
+			wantErr: `move: dir must be north, south, east or west, not "up"`,
+		},
+		{
+			name:    "bad ability direction",
+			frame:   `{"v":1,"type":"ability","dir":"NORTH"}`,
+			wantErr: `ability: dir must be north, south, east or west, not "NORTH"`,
+		},
+		{
+			name:    "attack without a target",
+			frame:   `{"v":1,"type":"attack"}`,
+			wantErr: "attack: target is required",
+		},
+		{
+			name:    "use without an item",
+			frame:   `{"v":1,"type":"use","target":{"X":1,"Y":1}}`,
+			wantErr: "use: item is required",
+		},
+		{
+			name:    "unknown field",
+			frame:   `{"v":1,"type":"move","dir":"north","speed":9}`,
+			wantErr: `malformed command: json: unknown field "speed"`,
+		},
+		{
+			name:    "not JSON",
+			frame:   `move north`,
+			wantErr: "malformed command: invalid character 'm' looking for beginning of value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := ParseCommand([]byte(tt.frame))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cmd, tt.want) {
+				t.Errorf("parsed %+v, want %+v", cmd, tt.want)
+			}
+		})
+	}
+}
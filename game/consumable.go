@@ -0,0 +1,193 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"fmt"
+)
+
+// Consumable effects. A consumable is used up one at a time with the "use"
+// command.
+const (
+	// EffectHeal restores Power HP to the user or an ally within Range.
+	EffectHeal = "heal"
+	// EffectVision adds Power to the vision radius of the user or an ally
+	// within Range for Turns turns.
+	EffectVision = "vision"
+	// EffectTeleport moves the user to an explored, open tile at the target,
+	// or to a random free tile when no target is given.
+	EffectTeleport = "teleport"
+	// EffectBomb deals Power damage to every monster within Radius of a
+	// tile in sight and within Range, unless a wall shields it.
+	EffectBomb = "bomb"
+)
+
+// consumableEffects lists the effects content packs may use.
+var consumableEffects = map[string]bool{
+	EffectHeal:     true,
+	EffectVision:   true,
+	EffectTeleport: true,
+	EffectBomb:     true,
+}
+
+// useItem applies one of the player's consumables. It returns true, like
+// ProcessPlayerCommand, when no turn was spent.
+func useItem(player *Player, cmd Command, state *GameState) bool {
+	item := player.FindItem(cmd.Item)
+	switch {
+	case item == nil:
+		player.addMessage(fmt.Sprintf("You don't have a %s.", cmd.Item))
+		return true
+	case !item.IsConsumable:
+		player.addMessage(fmt.Sprintf("The %s can't be used.", item.Name))
+		return true
+	}
+	var used bool
+	switch item.Effect {
+	case EffectHeal, EffectVision:
+		used = useOnPlayer(player, item, cmd.Target, state)
+	case EffectTeleport:
+		used = teleport(player, item, cmd.Target, state)
+	case EffectBomb:
+		used = throwBomb(player, item, cmd.Target, state)
+	}
+	if !used {
+		return true
+	}
+	item.Count--
+	if item.Count <= 0 {
+		player.removeItem(item)
+	}
+	return false
+}
+
+// useOnPlayer drinks a potion or elixir, or hands it to the ally standing at
+// target.
+func useOnPlayer(player *Player, item *Item, target *dungeon.Point, state *GameState) bool {
+	recipient := player
+	if target != nil && *target != player.Position {
+		recipient = nil
+		for _, id := range state.PlayerIDs() {
+			if ally := state.Players[id]; ally.Position == *target && ally.Status != "defeated" {
+				recipient = ally
+			}
+		}
+		if recipient == nil {
+			player.addMessage("There is no ally there.")
+			return false
+		}
+		if Distance(player.Position, recipient.Position) > item.Range {
+			player.addMessage(fmt.Sprintf("%s is too far away.", recipient.CombatName()))
+			return false
+		}
+	}
+	event := Event{Type: EventItemUsed, Source: player.actor(), Item: item.Name, Effect: item.Effect}
+	if recipient != player {
+		event.Target = recipient.actor()
+	}
+	switch item.Effect {
+	case EffectHeal:
+		if recipient.HP >= recipient.MaxHP {
+			player.addMessage(fmt.Sprintf("%s is already at full health.", recipient.CombatName()))
+			return false
+		}
+		before := recipient.HP
+		recipient.HP += item.Power
+		if recipient.HP > recipient.MaxHP {
+			recipient.HP = recipient.MaxHP
+		}
+		event.Amount = recipient.HP - before
+	case EffectVision:
+		recipient.VisionBonus, recipient.VisionTurns = item.Power, item.Turns
+		event.Amount = item.Power
+	}
+	state.Emit(event)
+	return true
+}
+
+// teleport moves the player to target, which they must have explored, or to
+// a random free tile. A monster the player cannot see standing on target
+// does not stop the jump: they land on the nearest free tile instead, so the
+// scroll cannot be used to find monsters in the dark.
+func teleport(player *Player, item *Item, target *dungeon.Point, state *GameState) bool {
+	var dest dungeon.Point
+	if target != nil {
+		dest = *target
+		if _, ok := FindMonsterAt(state, &dest); ok && !state.VisibleTo(player)[dest] && openTile(state, dest) {
+			dest, _ = state.nearestFreeTile(dest)
+		}
+		if !player.ExploredFloor(state.Depth).Has(*target) || !freeTile(state, dest) {
+			player.addMessage("You can't teleport there.")
+			return false
+		}
+	} else {
+		var free []dungeon.Point
+		for y, row := range state.Dungeon {
+			for x := range row {
+				if p := (dungeon.Point{X: x, Y: y}); freeTile(state, p) {
+					free = append(free, p)
+				}
+			}
+		}
+		if len(free) == 0 {
+			player.addMessage("The scroll fizzles.")
+			return false
+		}
+		dest = free[state.RNG.Intn(len(free))]
+	}
+	from := player.Position
+	player.Position = dest
+	state.Emit(Event{Type: EventItemUsed, Source: player.actor(), Item: item.Name, Effect: item.Effect})
+	state.Emit(Event{Type: EventMoved, Source: player.actor(), From: &from})
+	return true
+}
+
+// freeTile reports whether pos is plain floor with nobody on it.
+func freeTile(state *GameState, pos dungeon.Point) bool {
+	if !openTile(state, pos) {
+		return false
+	}
+	for _, m := range state.Monsters {
+		if m.Position == pos {
+			return false
+		}
+	}
+	return true
+}
+
+// openTile reports whether pos is plain floor with no player on it, whether
+// or not a monster is there.
+func openTile(state *GameState, pos dungeon.Point) bool {
+	if pos.Y < 0 || pos.Y >= len(state.Dungeon) || pos.X < 0 || pos.X >= len(state.Dungeon[pos.Y]) {
+		return false
+	}
+	if state.Dungeon[pos.Y][pos.X] != dungeon.TileFloor {
+		return false
+	}
+	for _, p := range state.Players {
+		if p.Position == pos {
+			return false
+		}
+	}
+	return true
+}
+
+// throwBomb blasts every monster within the bomb's radius of target that the
+// blast can reach: walls shield monsters just as they block sight. The
+// thrower earns the XP for anything it kills.
+func throwBomb(player *Player, item *Item, target *dungeon.Point, state *GameState) bool {
+	if target == nil {
+		player.addMessage(fmt.Sprintf("Where do you want to throw the %s?", item.Name))
+		return false
+	}
+	if Distance(player.Position, *target) > item.Range || !CanSee(state.Dungeon, player.Position, *target, item.Range) {
+		player.addMessage("You can't throw that far.")
+		return false
+	}
+	state.Emit(Event{Type: EventItemUsed, Source: player.actor(), Item: item.Name, Effect: item.Effect, At: target})
+	for _, m := range state.Monsters {
+		if Distance(m.Position, *target) <= item.Radius && CanSee(state.Dungeon, *target, m.Position, item.Radius) {
+			Resolve(state, player, m, AttackBlast, Modifiers{Damage: item.Power})
+		}
+	}
+	return true
+}
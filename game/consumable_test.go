@@ -0,0 +1,172 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"testing"
+)
+
+// give puts count of the item with the given key in the player's pack.
+func give(p *Player, key string, count int) *Item {
+	item := ItemTemplates[key]
+	item.Count = count
+	p.Inventory = append(p.Inventory, &item)
+	return p.Inventory[len(p.Inventory)-1]
+}
+
+func use(item string, target *dungeon.Point) Command {
+	return Command{Version: 1, Type: CmdUse, Item: item, Target: target}
+}
+
+func TestHealingPotion(t *testing.T) {
+	tests := []struct {
+		name   string
+		hp     int
+		wantHP int
+		used   bool
+	}{
+		{"wounded", 50, 80, true},
+		{"clamped to max HP", 120, 130, true},
+		{"already at full health", 130, 130, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs, marks := arena(t, "#####", "#@..#", "#####")
+			p := join(gs, "alice-1", "warrior", marks['@'][0])
+			give(p, "healing_potion", 2)
+			p.HP = tt.hp
+			_, early := ProcessPlayerCommand(p.ID, use("Healing Potion", nil), gs)
+			if p.HP != tt.wantHP {
+				t.Errorf("HP %d, want %d", p.HP, tt.wantHP)
+			}
+			if early == tt.used {
+				t.Errorf("turn spent: %v, want %v", !early, tt.used)
+			}
+			left := 2
+			if tt.used {
+				left = 1
+				if got := gs.Events[0].Amount; got != tt.wantHP-tt.hp {
+					t.Errorf("event says %d HP healed, want %d", got, tt.wantHP-tt.hp)
+				}
+			}
+			if got := p.FindItem("Healing Potion").Count; got != left {
+				t.Errorf("%d potions left, want %d", got, left)
+			}
+		})
+	}
+}
+
+// The last potion of a stack leaves the inventory when it is drunk.
+func TestLastPotionUsedUp(t *testing.T) {
+	gs, marks := arena(t, "#####", "#@..#", "#####")
+	p := join(gs, "alice-1", "cleric", marks['@'][0])
+	p.HP = 10
+	ProcessPlayerCommand(p.ID, use("Healing Potion", nil), gs)
+	if p.FindItem("Healing Potion") != nil {
+		t.Error("empty potion stack still in the inventory")
+	}
+}
+
+func TestPickupStacksConsumables(t *testing.T) {
+	gs, marks := arena(t, "#####", "#@*.#", "#####")
+	p := join(gs, "alice-1", "warrior", marks['@'][0])
+	give(p, "bomb", 2)
+	ground := ItemTemplates["bomb"]
+	ground.Count = 3
+	gs.ItemsOnGround[marks['*'][0]] = &ground
+	before := len(p.Inventory)
+	ProcessPlayerCommand(p.ID, Command{Version: 1, Type: CmdMove, Dir: "east"}, gs)
+	ProcessPlayerCommand(p.ID, Command{Version: 1, Type: CmdPickup}, gs)
+	if len(p.Inventory) != before {
+		t.Errorf("inventory grew from %d to %d items", before, len(p.Inventory))
+	}
+	if got := p.FindItem("Bomb").Count; got != 5 {
+		t.Errorf("stack of %d bombs, want 5", got)
+	}
+	if _, ok := gs.ItemsOnGround[marks['*'][0]]; ok {
+		t.Error("bombs still on the ground")
+	}
+}
+
+func TestTeleportDestination(t *testing.T) {
+	rows := []string{
+		"##############",
+		"#@..g..a.#...#",
+		"#........#.u.#",
+		"##############",
+	}
+	tests := []struct {
+		name   string
+		target rune
+		want   rune
+	}{
+		{"open floor", '.', '.'},
+		{"unexplored", 'u', '@'},
+		{"wall", '#', '@'},
+		{"monster in sight", 'g', '@'},
+		{"ally", 'a', '@'},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs, marks := arena(t, rows...)
+			marks['.'] = []dungeon.Point{{X: 3, Y: 2}}
+			marks['#'] = []dungeon.Point{{X: 9, Y: 1}}
+			p := join(gs, "alice-1", "warrior", marks['@'][0])
+			join(gs, "bobby-2", "ranger", marks['a'][0])
+			spawn(gs, marks['g'][0], 10, 6, 10)
+			give(p, "teleport_scroll", 1)
+			target := marks[tt.target][0]
+			_, early := ProcessPlayerCommand(p.ID, use("Teleport Scroll", &target), gs)
+			if want := marks[tt.want][0]; p.Position != want {
+				t.Errorf("player at %v, want %v", p.Position, want)
+			}
+			if early != (tt.want == '@') {
+				t.Errorf("turn spent: %v", !early)
+			}
+		})
+	}
+}
+
+// Without a target the scroll picks a random tile that is floor and free.
+func TestTeleportAnywhere(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		gs := newParty(t, seed)
+		p := gs.Players["alice-1"]
+		give(p, "teleport_scroll", 1)
+		if _, early := ProcessPlayerCommand(p.ID, use("Teleport Scroll", nil), gs); early {
+			t.Fatalf("seed %d: the scroll fizzled", seed)
+		}
+		dest := p.Position
+		p.Position = dungeon.Point{X: -1, Y: -1}
+		if !freeTile(gs, dest) {
+			t.Errorf("seed %d: landed on %v, which is not a free tile", seed, dest)
+		}
+	}
+}
+
+func TestBombRadius(t *testing.T) {
+	gs, marks := arena(t,
+		"#########",
+		"#......f#",
+		"#@..t#w.#",
+		"#...a...#",
+		"#...b...#",
+		"#########",
+	)
+	p := join(gs, "alice-1", "warrior", marks['@'][0])
+	bomb := give(p, "bomb", 1)
+	bomb.Radius = 2
+	monsters := map[rune]*Monster{}
+	for _, r := range "tabwf" {
+		monsters[r] = spawn(gs, marks[r][0], 100, 6, 10)
+	}
+	target := marks['t'][0]
+	if _, early := ProcessPlayerCommand(p.ID, use("Bomb", &target), gs); early {
+		t.Fatal("bomb not thrown")
+	}
+	for r, m := range monsters {
+		hit := m.CurrentHP == 100-bomb.Power
+		if want := r == 't' || r == 'a' || r == 'b'; hit != want {
+			t.Errorf("monster %c at %v hit: %v, want %v", r, m.Position, hit, want)
+		}
+	}
+}
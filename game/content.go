@@ -0,0 +1,503 @@
+package game
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"dunExpo/dungeon"
+)
+
+// SpawnTable controls how much of each thing is placed on a floor.
+type SpawnTable struct {
+	Monsters int `json:"monsters"`
+	// MonstersPerDepth is added to Monsters for every floor below the first.
+	MonstersPerDepth int `json:"monstersPerDepth"`
+	Fountains        int `json:"fountains"`
+	// Items maps an ItemTemplates key to how many are placed per floor.
+	Items map[string]int `json:"items"`
+}
+
+// PlayerStats are the base stats every new player starts with.
+type PlayerStats struct {
+	HP           int `json:"hp"`
+	Attack       int `json:"attack"`
+	VisionRadius int `json:"visionRadius"`
+	Speed        int `json:"speed"`
+}
+
+// Spawns and PlayerBase hold the active spawn table and player stats. Like
+// Bestiary and ItemTemplates they come from the built-in content pack and can
+// be overridden with LoadContentDir.
+var (
+	Spawns     SpawnTable
+	PlayerBase PlayerStats
+)
+
+//go:embed content/base.json
+var baseContent []byte
+
+// contentColors maps the colour names used in content packs to terminal
+// colours.
+var contentColors = map[string]string{
+	"grey":    dungeon.ColorGrey,
+	"white":   dungeon.ColorWhite,
+	"yellow":  dungeon.ColorYellow,
+	"green":   dungeon.ColorGreen,
+	"red":     dungeon.ColorRed,
+	"cyan":    dungeon.ColorCyan,
+	"magenta": dungeon.ColorMagenta,
+}
+
+// contentPack is the file format of a content pack. Every section is
+// optional; monsters, items and classes replace entries with the same key,
+// while spawns, player and levels replace the whole table.
+type contentPack struct {
+	Monsters map[string]monsterEntry `json:"monsters"`
+	Items    map[string]itemEntry    `json:"items"`
+	Classes  map[string]classEntry   `json:"classes"`
+	Spawns   *SpawnTable             `json:"spawns"`
+	Player   *PlayerStats            `json:"player"`
+	Levels   *LevelTable             `json:"levels"`
+}
+
+type monsterEntry struct {
+	Name         string `json:"name"`
+	Rune         string `json:"rune"`
+	Color        string `json:"color"`
+	HP           int    `json:"hp"`
+	Attack       int    `json:"attack"`
+	SpawnType    string `json:"spawnType"`
+	VisionRadius int    `json:"visionRadius"`
+	LeashRadius  int    `json:"leashRadius"`
+	AttackRange  int    `json:"attackRange"`
+	// AttackKind is "melee", "ranged" or "smite". Left out, it is "ranged"
+	// for an attackRange above 1 and "melee" otherwise.
+	AttackKind  string `json:"attackKind"`
+	MovingSpeed int    `json:"movingSpeed"`
+	XP          int    `json:"xp"`
+}
+
+type itemEntry struct {
+	Name       string `json:"name"`
+	Rune       string `json:"rune"`
+	Color      string `json:"color"`
+	Kind       string `json:"kind"`
+	Damage     int    `json:"damage"`
+	Range      int    `json:"range"`
+	Durability int    `json:"durability"`
+	Effect     string `json:"effect"`
+	Power      int    `json:"power"`
+	Radius     int    `json:"radius"`
+	Turns      int    `json:"turns"`
+	// Count is the stack size of a consumable as it is found; zero means 1.
+	Count int `json:"count"`
+}
+
+type classEntry struct {
+	Name string `json:"name"`
+	PlayerStats
+	Gear    []string `json:"gear"`
+	Ability string   `json:"ability"`
+}
+
+// content is a complete set of game data, built up one pack at a time.
+type content struct {
+	monsters map[string]MonsterTemplate
+	items    map[string]Item
+	classes  map[string]Class
+	spawns   SpawnTable
+	player   PlayerStats
+	levels   LevelTable
+}
+
+func init() {
+	c := &content{monsters: map[string]MonsterTemplate{}, items: map[string]Item{}, classes: map[string]Class{}}
+	if err := c.apply(baseContent, "base.json"); err != nil {
+		panic(err)
+	}
+	if err := c.check(); err != nil {
+		panic(err)
+	}
+	c.install()
+}
+
+// LoadContentDir applies every *.json content pack in dir, in file name
+// order, on top of the data currently in use. Nothing changes unless every
+// pack is valid. It returns the files that were applied.
+func LoadContentDir(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	c := current()
+	var problems []error
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if err := c.apply(data, file); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if len(problems) == 0 {
+		problems = append(problems, c.check())
+	}
+	if err := errors.Join(problems...); err != nil {
+		return nil, err
+	}
+	c.install()
+	return files, nil
+}
+
+// current copies the data in use so packs can be layered on top of it.
+func current() *content {
+	c := &content{
+		monsters: make(map[string]MonsterTemplate, len(Bestiary)),
+		items:    make(map[string]Item, len(ItemTemplates)),
+		classes:  make(map[string]Class, len(Classes)),
+		spawns:   Spawns,
+		player:   PlayerBase,
+		levels:   Levels,
+	}
+	for k, v := range Bestiary {
+		c.monsters[k] = v
+	}
+	for k, v := range ItemTemplates {
+		c.items[k] = v
+	}
+	for k, v := range Classes {
+		c.classes[k] = v
+	}
+	return c
+}
+
+func (c *content) install() {
+	Bestiary = c.monsters
+	ItemTemplates = c.items
+	Classes = c.classes
+	Spawns = c.spawns
+	PlayerBase = c.player
+	Levels = c.levels
+}
+
+// apply decodes one pack and merges its valid entries, reporting every bad
+// entry prefixed with source.
+func (c *content) apply(data []byte, source string) error {
+	var pack contentPack
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&pack); err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	var problems []error
+	for _, key := range sortedKeys(pack.Monsters) {
+		template, err := pack.Monsters[key].template()
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: monsters[%q]: %w", source, key, err))
+			continue
+		}
+		c.monsters[key] = template
+	}
+	for _, key := range sortedKeys(pack.Items) {
+		item, err := pack.Items[key].item()
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: items[%q]: %w", source, key, err))
+			continue
+		}
+		c.items[key] = item
+	}
+	for _, key := range sortedKeys(pack.Classes) {
+		class, err := pack.Classes[key].class()
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: classes[%q]: %w", source, key, err))
+			continue
+		}
+		c.classes[key] = class
+	}
+	if pack.Spawns != nil {
+		if err := pack.Spawns.validate(); err != nil {
+			problems = append(problems, fmt.Errorf("%s: spawns: %w", source, err))
+		} else {
+			c.spawns = *pack.Spawns
+		}
+	}
+	if pack.Player != nil {
+		if err := pack.Player.validate(); err != nil {
+			problems = append(problems, fmt.Errorf("%s: player: %w", source, err))
+		} else {
+			c.player = *pack.Player
+		}
+	}
+	if pack.Levels != nil {
+		if err := pack.Levels.validate(); err != nil {
+			problems = append(problems, fmt.Errorf("%s: levels: %w", source, err))
+		} else {
+			c.levels = *pack.Levels
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// check verifies the references between sections once all packs are applied.
+func (c *content) check() error {
+	var problems []error
+	if g, ok := c.monsters["guardian"]; !ok || g.SpawnType != "guardian" {
+		problems = append(problems, errors.New(`monsters: a "guardian" entry with spawnType "guardian" is required`))
+	}
+	for _, key := range sortedKeys(c.spawns.Items) {
+		if _, ok := c.items[key]; !ok {
+			problems = append(problems, fmt.Errorf("spawns.items: unknown item %q", key))
+		}
+	}
+	for _, key := range sortedKeys(c.classes) {
+		for _, item := range c.classes[key].Gear {
+			if _, ok := c.items[item]; !ok {
+				problems = append(problems, fmt.Errorf("classes[%q].gear: unknown item %q", key, item))
+			}
+		}
+	}
+	return errors.Join(problems...)
+}
+
+func (e monsterEntry) template() (MonsterTemplate, error) {
+	var problems []error
+	r, err := parseRune(e.Rune)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	color, ok := contentColors[e.Color]
+	if !ok {
+		problems = append(problems, fmt.Errorf("unknown color %q", e.Color))
+	}
+	if e.Name == "" {
+		problems = append(problems, errors.New("name is required"))
+	}
+	if e.HP <= 0 {
+		problems = append(problems, errors.New("hp must be positive"))
+	}
+	if e.Attack < 0 {
+		problems = append(problems, errors.New("attack must not be negative"))
+	}
+	switch e.SpawnType {
+	case "pack", "single", "guardian":
+	default:
+		problems = append(problems, fmt.Errorf("spawnType must be pack, single or guardian, not %q", e.SpawnType))
+	}
+	if e.VisionRadius < 0 || e.LeashRadius < 0 {
+		problems = append(problems, errors.New("visionRadius and leashRadius must not be negative"))
+	}
+	if e.AttackRange < 1 {
+		problems = append(problems, errors.New("attackRange must be at least 1"))
+	}
+	kind := AttackKind(e.AttackKind)
+	switch {
+	case kind == "" && e.AttackRange > 1:
+		kind = AttackRanged
+	case kind == "":
+		kind = AttackMelee
+	case kind == AttackMelee:
+		if e.AttackRange > 1 {
+			problems = append(problems, errors.New(`attackKind "melee" needs an attackRange of 1`))
+		}
+	case kind == AttackRanged || kind == AttackSmite:
+		if e.AttackRange <= 1 {
+			problems = append(problems, fmt.Errorf("attackKind %q needs an attackRange above 1", kind))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("attackKind must be melee, ranged or smite, not %q", e.AttackKind))
+	}
+	if e.MovingSpeed < 1 || e.MovingSpeed > 4 {
+		problems = append(problems, errors.New("movingSpeed must be between 1 and 4"))
+	}
+	if e.XP < 0 {
+		problems = append(problems, errors.New("xp must not be negative"))
+	}
+	return MonsterTemplate{
+		Name:         e.Name,
+		Rune:         r,
+		Color:        color,
+		HP:           e.HP,
+		Attack:       e.Attack,
+		SpawnType:    e.SpawnType,
+		VisionRadius: e.VisionRadius,
+		LeashRadius:  e.LeashRadius,
+		AttackRange:  e.AttackRange,
+		AttackKind:   kind,
+		MovingSpeed:  e.MovingSpeed,
+		XP:           e.XP,
+	}, joinProblems(problems)
+}
+
+func (e itemEntry) item() (Item, error) {
+	var problems []error
+	r, err := parseRune(e.Rune)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	color, ok := contentColors[e.Color]
+	if !ok {
+		problems = append(problems, fmt.Errorf("unknown color %q", e.Color))
+	}
+	if e.Name == "" {
+		problems = append(problems, errors.New("name is required"))
+	}
+	item := Item{Name: e.Name, Rune: r, Color: color}
+	switch e.Kind {
+	case "weapon":
+		if e.Damage <= 0 {
+			problems = append(problems, errors.New("weapon damage must be positive"))
+		}
+		if e.Range < 1 {
+			problems = append(problems, errors.New("weapon range must be at least 1"))
+		}
+		item.IsWeapon, item.Damage, item.Range = true, e.Damage, e.Range
+	case "armor":
+		if e.Durability <= 0 {
+			problems = append(problems, errors.New("armor durability must be positive"))
+		}
+		item.IsArmor, item.Durability = true, e.Durability
+	case "consumable":
+		problems = append(problems, e.checkConsumable()...)
+		count := e.Count
+		if count == 0 {
+			count = 1
+		}
+		item.IsConsumable, item.Effect, item.Count = true, e.Effect, count
+		item.Power, item.Range, item.Radius, item.Turns = e.Power, e.Range, e.Radius, e.Turns
+	default:
+		problems = append(problems, fmt.Errorf("kind must be weapon, armor or consumable, not %q", e.Kind))
+	}
+	return item, joinProblems(problems)
+}
+
+func (e classEntry) class() (Class, error) {
+	var problems []error
+	if e.Name == "" {
+		problems = append(problems, errors.New("name is required"))
+	}
+	if err := e.PlayerStats.validate(); err != nil {
+		problems = append(problems, err)
+	}
+	if _, ok := abilityCooldowns[e.Ability]; !ok {
+		problems = append(problems, fmt.Errorf("ability must be one of %s, not %q", strings.Join(sortedKeys(abilityCooldowns), ", "), e.Ability))
+	}
+	return Class{
+		Name:    e.Name,
+		Stats:   e.PlayerStats,
+		Gear:    append([]string(nil), e.Gear...),
+		Ability: e.Ability,
+	}, joinProblems(problems)
+}
+
+func (e itemEntry) checkConsumable() []error {
+	var problems []error
+	if !consumableEffects[e.Effect] {
+		problems = append(problems, fmt.Errorf("effect must be one of %s, not %q", strings.Join(sortedKeys(consumableEffects), ", "), e.Effect))
+	}
+	if e.Effect != EffectTeleport && e.Power <= 0 {
+		problems = append(problems, errors.New("consumable power must be positive"))
+	}
+	if e.Effect == EffectVision && e.Turns <= 0 {
+		problems = append(problems, errors.New("vision turns must be positive"))
+	}
+	if e.Effect == EffectBomb && e.Range < 1 {
+		problems = append(problems, errors.New("bomb range must be at least 1"))
+	}
+	if e.Range < 0 || e.Radius < 0 || e.Count < 0 {
+		problems = append(problems, errors.New("range, radius and count must not be negative"))
+	}
+	return problems
+}
+
+func (t *SpawnTable) validate() error {
+	var problems []error
+	if t.Monsters < 0 || t.MonstersPerDepth < 0 || t.Fountains < 0 {
+		problems = append(problems, errors.New("counts must not be negative"))
+	}
+	for _, key := range sortedKeys(t.Items) {
+		if t.Items[key] < 0 {
+			problems = append(problems, fmt.Errorf("items[%q]: count must not be negative", key))
+		}
+	}
+	return joinProblems(problems)
+}
+
+func (s *PlayerStats) validate() error {
+	var problems []error
+	if s.HP <= 0 {
+		problems = append(problems, errors.New("hp must be positive"))
+	}
+	if s.Attack < 0 || s.VisionRadius < 0 {
+		problems = append(problems, errors.New("attack and visionRadius must not be negative"))
+	}
+	if s.Speed < 1 || s.Speed > 4 {
+		problems = append(problems, errors.New("speed must be between 1 and 4"))
+	}
+	return joinProblems(problems)
+}
+
+func (t *LevelTable) validate() error {
+	var problems []error
+	for i, xp := range t.Thresholds {
+		if xp <= 0 || (i > 0 && xp <= t.Thresholds[i-1]) {
+			problems = append(problems, errors.New("thresholds must be positive and increasing"))
+			break
+		}
+	}
+	if t.HP < 0 || t.Attack < 0 || t.VisionRadius < 0 {
+		problems = append(problems, errors.New("hp, attack and visionRadius gains must not be negative"))
+	}
+	return joinProblems(problems)
+}
+
+// levelSpec turns the spawn table into a generator spec for the standard map
+// size.
+func (t SpawnTable) levelSpec() dungeon.Spec {
+	return dungeon.Spec{
+		Width:     dungeon.MapWidth,
+		Height:    dungeon.MapHeight,
+		Fountains: t.Fountains,
+		Items:     t.Items,
+	}
+}
+
+// joinProblems folds the problems with one entry into a single line so each
+// line of a LoadContentDir error names the entry it is about.
+func joinProblems(problems []error) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(problems))
+	for i, p := range problems {
+		msgs[i] = p.Error()
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+func parseRune(s string) (rune, error) {
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, fmt.Errorf("rune must be a single character, not %q", s)
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,145 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// monsterJSON is a valid monster entry with extra fields spliced in.
+func monsterJSON(extra string) string {
+	return `{"monsters": {"archer": {"name": "Bone Archer", "rune": "a", "color": "white", "hp": 10, "attack": 4, "spawnType": "single", "visionRadius": 8, "leashRadius": 10, "movingSpeed": 1, "xp": 5` + extra + `}}}`
+}
+
+func TestMonsterAttackKind(t *testing.T) {
+	tests := []struct {
+		name    string
+		extra   string
+		want    AttackKind
+		problem string
+	}{
+		{"melee by default", `, "attackRange": 1`, AttackMelee, ""},
+		{"ranged by default", `, "attackRange": 5`, AttackRanged, ""},
+		{"smite", `, "attackRange": 3, "attackKind": "smite"`, AttackSmite, ""},
+		{"melee with reach", `, "attackRange": 3, "attackKind": "melee"`, "", `attackKind "melee" needs an attackRange of 1`},
+		{"ranged without reach", `, "attackRange": 1, "attackKind": "ranged"`, "", `attackKind "ranged" needs an attackRange above 1`},
+		{"unknown kind", `, "attackRange": 2, "attackKind": "bite"`, "", `attackKind must be melee, ranged or smite, not "bite"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := current()
+			err := c.apply([]byte(monsterJSON(tt.extra)), "pack.json")
+			if tt.problem != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.problem) {
+					t.Fatalf("got %v, want a problem mentioning %q", err, tt.problem)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.monsters["archer"].AttackKind; got != tt.want {
+				t.Errorf("attack kind %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// A monster's ranged attack comes from its template, not its name.
+func TestRenamedArcherStillShoots(t *testing.T) {
+	tiles, marks := fixture(
+		"##########",
+		"#M....@..#",
+		"##########",
+	)
+	template := &MonsterTemplate{Name: "Bone Archer", HP: 10, Attack: 4, VisionRadius: 8, LeashRadius: 10, AttackRange: 6, AttackKind: AttackRanged, MovingSpeed: 1}
+	m := &Monster{ID: 1, Template: template, Position: marks['M'][0], CurrentHP: 10, SpawnPoint: marks['M'][0]}
+	p := &Player{ID: "target-1", Position: marks['@'][0], HP: 50, MaxHP: 50, Status: "playing"}
+	state := &GameState{
+		Floor:   &Floor{Dungeon: tiles, Monsters: []*Monster{m}},
+		Players: map[string]*Player{p.ID: p},
+	}
+	m.takeTurn(state)
+	if p.HP != 46 || m.Position != marks['M'][0] {
+		t.Errorf("player HP %d and archer at %v; want the archer to shoot from where it stands", p.HP, m.Position)
+	}
+	if len(state.Events) == 0 || state.Events[0].Kind != AttackRanged {
+		t.Errorf("events %+v, want a ranged attack", state.Events)
+	}
+}
+
+// loadPack applies one pack on top of the data in use and checks the result,
+// as LoadContentDir does, without installing it.
+func loadPack(data string) error {
+	c := current()
+	if err := c.apply([]byte(data), "pack.json"); err != nil {
+		return err
+	}
+	return c.check()
+}
+
+func TestContentPackValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		pack string
+		// problem is part of the error expected, or "" for a valid pack.
+		problem string
+	}{
+		{"valid", monsterJSON(`, "attackRange": 1`), ""},
+		{"malformed JSON", `{"monsters": {`, "pack.json: unexpected EOF"},
+		{"unknown section", `{"spells": {}}`, `pack.json: json: unknown field "spells"`},
+		{"unknown monster field", monsterJSON(`, "attackRange": 1, "flying": true`), `unknown field "flying"`},
+		{"player speed too high", `{"player": {"hp": 100, "attack": 10, "visionRadius": 5, "speed": 9}}`, "pack.json: player: speed must be between 1 and 4"},
+		{"class speed too low", `{"classes": {"monk": {"name": "Monk", "hp": 90, "attack": 9, "visionRadius": 5, "speed": 0, "ability": "healAlly"}}}`, `pack.json: classes["monk"]: speed must be between 1 and 4`},
+		{"monster speed out of range", `{"monsters": {"bat": {"name": "Bat", "rune": "b", "color": "magenta", "hp": 3, "attack": 2, "spawnType": "pack", "attackRange": 1, "movingSpeed": 5}}}`, `pack.json: monsters["bat"]: movingSpeed must be between 1 and 4`},
+		{"guardian replaced", `{"monsters": {"guardian": {"name": "Statue", "rune": "S", "color": "grey", "hp": 50, "attack": 5, "spawnType": "single", "attackRange": 1, "movingSpeed": 1}}}`, `a "guardian" entry with spawnType "guardian" is required`},
+		{"spawn of unknown item", `{"spawns": {"monsters": 5, "fountains": 1, "items": {"wand": 1}}}`, `spawns.items: unknown item "wand"`},
+		{"gear of unknown item", `{"classes": {"monk": {"name": "Monk", "hp": 90, "attack": 9, "visionRadius": 5, "speed": 2, "gear": ["staff"], "ability": "healAlly"}}}`, `classes["monk"].gear: unknown item "staff"`},
+		{"unknown ability", `{"classes": {"monk": {"name": "Monk", "hp": 90, "attack": 9, "visionRadius": 5, "speed": 2, "ability": "meditate"}}}`, `ability must be one of`},
+		{"every problem of an entry on one line", `{"items": {"wand": {"name": "", "rune": "ab", "color": "blue", "kind": "weapon", "damage": 0, "range": 1}}}`, `items["wand"]: rune must be a single character, not "ab"; unknown color "blue"; name is required; weapon damage must be positive`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := loadPack(tt.pack)
+			switch {
+			case tt.problem == "" && err != nil:
+				t.Errorf("unexpected problem: %v", err)
+			case tt.problem != "" && (err == nil || !strings.Contains(err.Error(), tt.problem)):
+				t.Errorf("got %v, want a problem mentioning %q", err, tt.problem)
+			}
+		})
+	}
+}
+
+// A directory with one broken pack changes nothing, even where the other
+// packs are valid.
+func TestLoadContentDirIsAllOrNothing(t *testing.T) {
+	dir := t.TempDir()
+	writePack := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := Bestiary["goblin"].HP
+	writePack("a-tough-goblins.json", `{"monsters": {"goblin": {"name": "Goblin", "rune": "g", "color": "green", "hp": 70, "attack": 6, "spawnType": "pack", "visionRadius": 8, "leashRadius": 12, "attackRange": 1, "movingSpeed": 2, "xp": 10}}}`)
+	writePack("b-broken.json", `{"player": {"hp": 0, "speed": 2}}`)
+	files, err := LoadContentDir(dir)
+	if err == nil || !strings.Contains(err.Error(), "b-broken.json: player: hp must be positive") {
+		t.Fatalf("got %v, want the broken pack named", err)
+	}
+	if files != nil || Bestiary["goblin"].HP != before || PlayerBase.HP <= 0 {
+		t.Errorf("a failed load changed the content: goblin HP %d, was %d", Bestiary["goblin"].HP, before)
+	}
+
+	defer current().install()
+	if err := os.Remove(filepath.Join(dir, "b-broken.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadContentDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if Bestiary["goblin"].HP != 70 {
+		t.Errorf("goblin HP %d after a good load, want 70", Bestiary["goblin"].HP)
+	}
+}
@@ -0,0 +1,198 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"encoding/json"
+	"sort"
+)
+
+// KeyframeInterval is how many deltas a client receives between full state
+// keyframes. Keyframes bound how long a client that missed an update can
+// stay out of sync.
+const KeyframeInterval = 50
+
+// TileChange is a single map tile that differs from what the client has.
+type TileChange struct {
+	X, Y int
+	Tile int
+}
+
+// StateDelta carries only what changed since the previous update sent to a
+// client. Entities are sent whole when added or changed and by key when
+// removed, in a stable order; nil fields are unchanged. The log and the
+// tile lists are sent whole whenever they change.
+type StateDelta struct {
+	Tiles            []TileChange      `json:",omitempty"`
+	ExitPos          *dungeon.Point    `json:",omitempty"`
+	Monsters         []json.RawMessage `json:",omitempty"`
+	RemovedMonsters  []int             `json:",omitempty"`
+	Players          []json.RawMessage `json:",omitempty"`
+	RemovedPlayers   []string          `json:",omitempty"`
+	ItemsOnGround    []json.RawMessage `json:",omitempty"`
+	RemovedItems     []dungeon.Point   `json:",omitempty"`
+	Log              json.RawMessage   `json:",omitempty"`
+	HighlightedTiles json.RawMessage   `json:",omitempty"`
+	VisibleTiles     json.RawMessage   `json:",omitempty"`
+	// Events are always this update's own, never a change to earlier ones.
+	Events []Event `json:",omitempty"`
+}
+
+// ClientView remembers what one client has been sent so the next update
+// can be a delta. The zero value has sent nothing and so starts with a
+// keyframe.
+type ClientView struct {
+	valid         bool
+	sinceKeyframe int
+	depth         int
+	exitKnown     bool
+	tiles         [][]int
+	monsters      map[int]string
+	players       map[string]string
+	items         map[dungeon.Point]string
+	log           string
+	highlighted   string
+	visible       string
+}
+
+// Reset forgets everything sent so the next update is a keyframe. Clients
+// ask for this when they believe they are out of sync.
+func (v *ClientView) Reset() {
+	v.valid = false
+}
+
+// Update records state as sent and returns the message to send for it: a
+// full "state" keyframe, or a "delta" holding only the changes.
+func (v *ClientView) Update(state GameStateForJSON) (string, interface{}, error) {
+	keyframe := !v.valid || v.depth != state.Depth || v.sinceKeyframe >= KeyframeInterval
+
+	monsters := make(map[int]string, len(state.Monsters))
+	monsterOrder := make([]int, 0, len(state.Monsters))
+	for _, m := range state.Monsters {
+		raw, err := json.Marshal(m)
+		if err != nil {
+			return "", nil, err
+		}
+		monsters[m.ID] = string(raw)
+		monsterOrder = append(monsterOrder, m.ID)
+	}
+	players := make(map[string]string, len(state.Players))
+	for id, p := range state.Players {
+		raw, err := json.Marshal(p)
+		if err != nil {
+			return "", nil, err
+		}
+		players[id] = string(raw)
+	}
+	items := make(map[dungeon.Point]string, len(state.ItemsOnGround))
+	itemOrder := make([]dungeon.Point, 0, len(state.ItemsOnGround))
+	for _, it := range state.ItemsOnGround {
+		raw, err := json.Marshal(it)
+		if err != nil {
+			return "", nil, err
+		}
+		items[it.Position] = string(raw)
+		itemOrder = append(itemOrder, it.Position)
+	}
+	logRaw, err := json.Marshal(state.Log)
+	if err != nil {
+		return "", nil, err
+	}
+	highlightedRaw, err := json.Marshal(state.HighlightedTiles)
+	if err != nil {
+		return "", nil, err
+	}
+	visibleRaw, err := json.Marshal(state.VisibleTiles)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var delta StateDelta
+	if !keyframe {
+		for y, row := range state.Dungeon {
+			for x, tile := range row {
+				if v.tiles[y][x] != tile {
+					delta.Tiles = append(delta.Tiles, TileChange{X: x, Y: y, Tile: tile})
+				}
+			}
+		}
+		for _, id := range monsterOrder {
+			if v.monsters[id] != monsters[id] {
+				delta.Monsters = append(delta.Monsters, json.RawMessage(monsters[id]))
+			}
+		}
+		for id := range v.monsters {
+			if _, ok := monsters[id]; !ok {
+				delta.RemovedMonsters = append(delta.RemovedMonsters, id)
+			}
+		}
+		sort.Ints(delta.RemovedMonsters)
+		for _, id := range sortedKeys(players) {
+			if v.players[id] != players[id] {
+				delta.Players = append(delta.Players, json.RawMessage(players[id]))
+			}
+		}
+		for _, id := range sortedKeys(v.players) {
+			if _, ok := players[id]; !ok {
+				delta.RemovedPlayers = append(delta.RemovedPlayers, id)
+			}
+		}
+		for _, pos := range itemOrder {
+			if v.items[pos] != items[pos] {
+				delta.ItemsOnGround = append(delta.ItemsOnGround, json.RawMessage(items[pos]))
+			}
+		}
+		for pos := range v.items {
+			if _, ok := items[pos]; !ok {
+				delta.RemovedItems = append(delta.RemovedItems, pos)
+			}
+		}
+		sort.Slice(delta.RemovedItems, func(i, j int) bool {
+			a, b := delta.RemovedItems[i], delta.RemovedItems[j]
+			return a.Y < b.Y || (a.Y == b.Y && a.X < b.X)
+		})
+		if !v.exitKnown && state.ExitPos != nil {
+			delta.ExitPos = state.ExitPos
+		}
+		if v.log != string(logRaw) {
+			delta.Log = logRaw
+		}
+		if v.highlighted != string(highlightedRaw) {
+			delta.HighlightedTiles = highlightedRaw
+		}
+		if v.visible != string(visibleRaw) {
+			delta.VisibleTiles = visibleRaw
+		}
+		delta.Events = state.Events
+	}
+
+	v.valid = true
+	v.depth = state.Depth
+	v.exitKnown = state.ExitPos != nil
+	v.tiles = copyTiles(v.tiles, state.Dungeon)
+	v.monsters = monsters
+	v.players = players
+	v.items = items
+	v.log = string(logRaw)
+	v.highlighted = string(highlightedRaw)
+	v.visible = string(visibleRaw)
+	if keyframe {
+		v.sinceKeyframe = 0
+		return "state", state, nil
+	}
+	v.sinceKeyframe++
+	return "delta", delta, nil
+}
+
+// copyTiles copies src into dst, reusing dst's rows when the sizes match.
+func copyTiles(dst, src [][]int) [][]int {
+	if len(dst) != len(src) {
+		dst = make([][]int, len(src))
+	}
+	for y, row := range src {
+		if len(dst[y]) != len(row) {
+			dst[y] = make([]int, len(row))
+		}
+		copy(dst[y], row)
+	}
+	return dst
+}
@@ -0,0 +1,98 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"reflect"
+	"testing"
+)
+
+// snapshot is a small view on the given floor.
+func snapshot(depth int, rows ...[]int) GameStateForJSON {
+	return GameStateForJSON{Depth: depth, Dungeon: rows, Players: map[string]*Player{}}
+}
+
+func TestClientViewKeyframeCadence(t *testing.T) {
+	var v ClientView
+	update := func() string {
+		kind, _, err := v.Update(snapshot(1, []int{dungeon.TileFloor}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return kind
+	}
+	if kind := update(); kind != "state" {
+		t.Fatalf("first update is a %q, want a keyframe", kind)
+	}
+	for i := 1; i <= KeyframeInterval; i++ {
+		if kind := update(); kind != "delta" {
+			t.Fatalf("update %d after the keyframe is a %q, want a delta", i, kind)
+		}
+	}
+	if kind := update(); kind != "state" {
+		t.Errorf("update %d after the keyframe is a %q, want a keyframe", KeyframeInterval+1, kind)
+	}
+	update()
+	v.Reset()
+	if kind := update(); kind != "state" {
+		t.Errorf("update after Reset is a %q, want a keyframe", kind)
+	}
+}
+
+func TestClientViewRemovals(t *testing.T) {
+	goblin := &MonsterTemplate{Name: "Goblin"}
+	before := snapshot(1, []int{dungeon.TileFloor})
+	for _, id := range []int{5, 2, 9, 1, 7} {
+		before.Monsters = append(before.Monsters, &Monster{ID: id, Template: goblin})
+	}
+	for _, pos := range []dungeon.Point{{X: 4, Y: 2}, {X: 1, Y: 3}, {X: 3, Y: 1}, {X: 2, Y: 2}} {
+		before.ItemsOnGround = append(before.ItemsOnGround, ItemOnGroundJSON{Position: pos, Item: &Item{Name: "Bomb"}})
+	}
+	before.Players = map[string]*Player{"a": {ID: "a"}, "b": {ID: "b"}}
+	before.Log = []string{"one"}
+	after := snapshot(1, []int{dungeon.TileFloor})
+	after.Monsters = before.Monsters[3:4]
+	after.Players = map[string]*Player{"a": {ID: "a"}}
+	after.Log = before.Log
+
+	// The same change gives the same delta every time.
+	for i := 0; i < 10; i++ {
+		var v ClientView
+		if _, _, err := v.Update(before); err != nil {
+			t.Fatal(err)
+		}
+		_, msg, err := v.Update(after)
+		if err != nil {
+			t.Fatal(err)
+		}
+		delta := msg.(StateDelta)
+		if want := []int{2, 5, 7, 9}; !reflect.DeepEqual(delta.RemovedMonsters, want) {
+			t.Fatalf("removed monsters %v, want %v", delta.RemovedMonsters, want)
+		}
+		if want := []dungeon.Point{{X: 3, Y: 1}, {X: 2, Y: 2}, {X: 4, Y: 2}, {X: 1, Y: 3}}; !reflect.DeepEqual(delta.RemovedItems, want) {
+			t.Fatalf("removed items %v, want %v", delta.RemovedItems, want)
+		}
+		if want := []string{"b"}; !reflect.DeepEqual(delta.RemovedPlayers, want) {
+			t.Fatalf("removed players %v, want %v", delta.RemovedPlayers, want)
+		}
+		if delta.Monsters != nil || delta.Players != nil || delta.ItemsOnGround != nil || delta.Log != nil {
+			t.Fatalf("unchanged entities resent: %+v", delta)
+		}
+	}
+}
+
+// A new floor is sent whole, and later tile changes are diffed against it
+// rather than the floor above.
+func TestClientViewTilesAfterDescent(t *testing.T) {
+	const f, w = dungeon.TileFloor, dungeon.TileWall
+	var v ClientView
+	v.Update(snapshot(1, []int{f, f, f}, []int{f, f, f}))
+	kind, _, _ := v.Update(snapshot(2, []int{w, w, w}, []int{f, f, w}))
+	if kind != "state" {
+		t.Fatalf("the new floor came as a %q, want a keyframe", kind)
+	}
+	_, msg, _ := v.Update(snapshot(2, []int{w, w, w}, []int{f, dungeon.TileHealth, w}))
+	want := []TileChange{{X: 1, Y: 1, Tile: dungeon.TileHealth}}
+	if got := msg.(StateDelta).Tiles; !reflect.DeepEqual(got, want) {
+		t.Errorf("tile changes %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+package game
+
+import "dunExpo/dungeon"
+
+// EventType names something that happened in the game.
+type EventType string
+
+const (
+	EventMoved          EventType = "moved"
+	EventAttacked       EventType = "attacked"
+	EventDamaged        EventType = "damaged"
+	EventArmorBroke     EventType = "armorBroke"
+	EventItemPickedUp   EventType = "itemPickedUp"
+	EventMonsterKilled  EventType = "monsterKilled"
+	EventPlayerDefeated EventType = "playerDefeated"
+	EventFountainUsed   EventType = "fountainUsed"
+	EventExitReached    EventType = "exitReached"
+	// EventAbilityUsed is a class ability; the attacks or healing it leads
+	// to follow as their own events.
+	EventAbilityUsed EventType = "abilityUsed"
+	EventXPGained    EventType = "xpGained"
+	EventLevelUp     EventType = "levelUp"
+	// EventItemUsed is a consumable being used; its effects follow as
+	// their own events where they have one.
+	EventItemUsed EventType = "itemUsed"
+)
+
+// Actor is a player or monster taking part in an event, as it stood when
+// the event happened.
+type Actor struct {
+	Player   string `json:",omitempty"`
+	Monster  int    `json:",omitempty"`
+	Name     string
+	Position dungeon.Point
+}
+
+// Event is a typed record of one thing the game logic did. The events of a
+// turn are sent to clients with the state, so they can animate hits or show
+// damage numbers, and FormatEvent renders them into each player's log.
+type Event struct {
+	Type EventType
+	// Turn is the turn the event happened on; clients use it to tell new
+	// events from ones repeated in a keyframe.
+	Turn int
+	// Source is who acted and Target who was acted on.
+	Source *Actor `json:",omitempty"`
+	Target *Actor `json:",omitempty"`
+	// Kind is how an attack was delivered.
+	Kind AttackKind `json:",omitempty"`
+	// Amount is the HP lost for EventDamaged, gained for EventFountainUsed
+	// or a heal, the XP of an EventXPGained and the vision added by an
+	// elixir.
+	Amount int `json:",omitempty"`
+	// Absorbed is the damage armor took instead of the target.
+	Absorbed int `json:",omitempty"`
+	// Item names the item picked up or used, or the armor that broke.
+	Item string `json:",omitempty"`
+	// Effect is the consumable effect of an EventItemUsed.
+	Effect string `json:",omitempty"`
+	// At is the tile a bomb was thrown at.
+	At *dungeon.Point `json:",omitempty"`
+	// Ability is the class ability of an EventAbilityUsed.
+	Ability string `json:",omitempty"`
+	// From is where an EventMoved started; Source.Position is where it ended.
+	From *dungeon.Point `json:",omitempty"`
+	// Level is the level reached with an EventLevelUp.
+	Level int `json:",omitempty"`
+	// Depth is the floor the party moved to after an EventExitReached, or 0
+	// when the exit won the run.
+	Depth int `json:",omitempty"`
+}
+
+// Emit records an event for this turn. Its text reaches the logs of the
+// players who may see it once the turn is over; see logEvents.
+func (gs *GameState) Emit(e Event) {
+	e.Turn = gs.Turn
+	gs.Events = append(gs.Events, e)
+}
+
+// eventsFor picks the events of the last change that a player who sees the
+// visible tiles may be sent.
+func (gs *GameState) eventsFor(playerID string, visible map[dungeon.Point]bool) []Event {
+	events := []Event{}
+	for _, e := range gs.Events {
+		if e.visibleTo(playerID, visible) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// logEvents renders the events each player may see into their log. It runs
+// at the end of a turn, with the same view ViewFor filters Events by, so a
+// player's log and events always tell the same story.
+func (gs *GameState) logEvents() {
+	for _, id := range gs.PlayerIDs() {
+		p := gs.Players[id]
+		for _, e := range gs.eventsFor(id, gs.VisibleTo(p)) {
+			if text := FormatEvent(e); text != "" {
+				p.addMessage(text)
+			}
+		}
+	}
+}
+
+// visibleTo reports whether a player may be sent the event: they took part
+// in it, or everyone involved is in view. Reaching the exit moves or ends
+// the game for the whole party, so everyone hears of it.
+func (e Event) visibleTo(playerID string, visible map[dungeon.Point]bool) bool {
+	if e.Type == EventExitReached {
+		return true
+	}
+	actors := []*Actor{e.Source, e.Target}
+	for _, a := range actors {
+		if a != nil && a.Player == playerID {
+			return true
+		}
+	}
+	for _, a := range actors {
+		if a != nil && !visible[a.Position] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Player) actor() *Actor {
+	return &Actor{Player: p.ID, Name: p.CombatName(), Position: p.Position}
+}
+
+func (m *Monster) actor() *Actor {
+	return &Actor{Monster: m.ID, Name: m.CombatName(), Position: m.Position}
+}
@@ -0,0 +1,171 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"reflect"
+	"testing"
+)
+
+// arena starts a run on a fixture map with nothing on it. 'F' marks a
+// fountain.
+func arena(t *testing.T, rows ...string) (*GameState, map[rune][]dungeon.Point) {
+	t.Helper()
+	gs, err := NewGameState(Config{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles, marks := fixture(rows...)
+	for _, p := range marks['F'] {
+		tiles[p.Y][p.X] = dungeon.TileHealth
+	}
+	gs.Floor = &Floor{Depth: 1, Dungeon: tiles, ItemsOnGround: map[dungeon.Point]*Item{}}
+	gs.Floors = []*Floor{gs.Floor}
+	return gs, marks
+}
+
+// join adds a player of the given class standing at pos, having explored
+// only what they can see from there.
+func join(gs *GameState, id, class string, pos dungeon.Point) *Player {
+	p := gs.AddPlayer(id, class)
+	p.Position, p.Explored = pos, nil
+	gs.UpdateExploration()
+	return p
+}
+
+// spawn puts a goblin-like melee monster with the given HP, attack and XP at
+// pos.
+func spawn(gs *GameState, pos dungeon.Point, hp, attack, xp int) *Monster {
+	m := &Monster{
+		ID:         len(gs.Monsters) + 1,
+		Template:   &MonsterTemplate{Name: "Goblin", HP: hp, Attack: attack, XP: xp, VisionRadius: 8, LeashRadius: 12, AttackRange: 1, AttackKind: AttackMelee, MovingSpeed: 1},
+		Position:   pos,
+		CurrentHP:  hp,
+		SpawnPoint: pos,
+	}
+	gs.Monsters = append(gs.Monsters, m)
+	return m
+}
+
+func eventTypes(events []Event) []EventType {
+	types := make([]EventType, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func TestStepEmitsEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     []string
+		goblinHP int
+		want     []EventType
+	}{
+		{
+			name:     "attack and counter-attack",
+			rows:     []string{"######", "#@g..#", "######"},
+			goblinHP: 20,
+			want:     []EventType{EventAttacked, EventDamaged, EventAttacked, EventDamaged},
+		},
+		{
+			name:     "kill",
+			rows:     []string{"######", "#@g..#", "######"},
+			goblinHP: 5,
+			want:     []EventType{EventAttacked, EventDamaged, EventMonsterKilled, EventXPGained},
+		},
+		{
+			name: "fountain",
+			rows: []string{"######", "#@F..#", "######"},
+			want: []EventType{EventMoved, EventFountainUsed},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs, marks := arena(t, tt.rows...)
+			p := join(gs, "alice-1", "", marks['@'][0])
+			p.Attack, p.HP = 5, 50
+			for _, pos := range marks['g'] {
+				spawn(gs, pos, tt.goblinHP, 6, 10)
+			}
+			gs.Step(ClientCommand{PlayerID: p.ID, Command: Command{Version: 1, Type: CmdMove, Dir: "east"}})
+			if got := eventTypes(gs.Events); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("events %v, want %v", got, tt.want)
+			}
+			for _, e := range gs.Events {
+				if e.Turn != 0 {
+					t.Errorf("%s event stamped turn %d, want 0", e.Type, e.Turn)
+				}
+			}
+		})
+	}
+}
+
+// The events carry who did what to whom and by how much.
+func TestStepEventDetails(t *testing.T) {
+	gs, marks := arena(t, "######", "#@gF.#", "######")
+	p := join(gs, "alice-1", "", marks['@'][0])
+	p.Attack, p.HP = 5, 40
+	goblin := spawn(gs, marks['g'][0], 5, 6, 10)
+	gs.Step(ClientCommand{PlayerID: p.ID, Command: Command{Version: 1, Type: CmdMove, Dir: "east"}})
+	hit, killed, xp := gs.Events[1], gs.Events[2], gs.Events[3]
+	if hit.Source.Player != p.ID || hit.Target.Monster != goblin.ID || hit.Kind != AttackMelee || hit.Amount != 5 {
+		t.Errorf("damage event %+v", hit)
+	}
+	if killed.Target.Name != "Goblin" || killed.Target.Position != marks['g'][0] {
+		t.Errorf("kill event %+v", killed)
+	}
+	if xp.Source.Player != p.ID || xp.Amount != 10 {
+		t.Errorf("XP event %+v", xp)
+	}
+
+	gs.Step(ClientCommand{PlayerID: p.ID, Command: Command{Version: 1, Type: CmdMove, Dir: "east"}})
+	gs.Step(ClientCommand{PlayerID: p.ID, Command: Command{Version: 1, Type: CmdMove, Dir: "east"}})
+	fountain := gs.Events[len(gs.Events)-1]
+	if fountain.Type != EventFountainUsed || fountain.Amount != 10 || fountain.Turn != 2 {
+		t.Errorf("fountain event %+v", fountain)
+	}
+}
+
+func TestFormatEvent(t *testing.T) {
+	alice := &Actor{Player: "alice-1", Name: "alic"}
+	bob := &Actor{Player: "bobby-2", Name: "bobb"}
+	goblin := &Actor{Monster: 1, Name: "Goblin"}
+	guardian := &Actor{Monster: 2, Name: "Guardian"}
+	tests := []struct {
+		event Event
+		want  string
+	}{
+		{Event{Type: EventMoved, Source: alice}, ""},
+		{Event{Type: EventAttacked, Source: alice, Target: goblin}, ""},
+		{Event{Type: EventDamaged, Source: alice, Target: goblin, Kind: AttackMelee, Amount: 12}, "alic attacks the Goblin for 12 damage!"},
+		{Event{Type: EventDamaged, Source: goblin, Target: alice, Kind: AttackMelee, Amount: 6}, "Goblin attacks alic for 6 damage!"},
+		{Event{Type: EventDamaged, Source: alice, Target: goblin, Kind: AttackRanged, Amount: 5}, "alic fires an arrow at the Goblin for 5 damage!"},
+		{Event{Type: EventDamaged, Source: guardian, Target: alice, Kind: AttackSmite, Amount: 18}, "The Guardian smites alic from afar for 18 damage!"},
+		{Event{Type: EventDamaged, Source: alice, Target: goblin, Kind: AttackBlast, Amount: 20}, "The blast hits the Goblin for 20 damage!"},
+		{Event{Type: EventDamaged, Source: goblin, Target: alice, Kind: AttackMelee, Absorbed: 6}, "alic's armor absorbs 6 damage!"},
+		{Event{Type: EventArmorBroke, Target: alice, Item: "Chainmail"}, "alic's Chainmail breaks!"},
+		{Event{Type: EventItemPickedUp, Source: alice, Item: "Bow"}, "alic picks up the Bow."},
+		{Event{Type: EventMonsterKilled, Source: alice, Target: goblin}, "Goblin is defeated!"},
+		{Event{Type: EventPlayerDefeated, Source: goblin, Target: alice}, "alic has been defeated by a Goblin!"},
+		{Event{Type: EventFountainUsed, Source: alice, Amount: 10}, "alic drinks from the fountain."},
+		{Event{Type: EventExitReached, Source: alice, Depth: 2}, "alic finds stairs leading down! The party descends to depth 2."},
+		{Event{Type: EventExitReached, Source: alice}, "alic has reached the exit! The party is victorious!"},
+		{Event{Type: EventAbilityUsed, Source: alice, Target: goblin, Ability: AbilityShieldBash}, "alic slams the Goblin with a shield!"},
+		{Event{Type: EventAbilityUsed, Source: alice, Ability: AbilityMultiShot}, "alic looses a volley of arrows!"},
+		{Event{Type: EventAbilityUsed, Source: alice, Ability: AbilityExtendedVision}, "alic scans the darkness ahead."},
+		{Event{Type: EventAbilityUsed, Source: alice, Target: bob, Ability: AbilityHealAlly, Amount: 25}, "alic heals bobb for 25 HP."},
+		{Event{Type: EventXPGained, Source: alice, Amount: 10}, ""},
+		{Event{Type: EventLevelUp, Source: alice, Level: 2}, "alic reaches level 2!"},
+		{Event{Type: EventItemUsed, Source: alice, Item: "Healing Potion", Effect: EffectHeal, Amount: 30}, "alic drinks the Healing Potion and recovers 30 HP."},
+		{Event{Type: EventItemUsed, Source: alice, Target: bob, Item: "Healing Potion", Effect: EffectHeal, Amount: 30}, "alic gives bobb the Healing Potion, restoring 30 HP."},
+		{Event{Type: EventItemUsed, Source: alice, Item: "Vision Elixir", Effect: EffectVision}, "alic drinks the Vision Elixir. The darkness recedes."},
+		{Event{Type: EventItemUsed, Source: alice, Target: bob, Item: "Vision Elixir", Effect: EffectVision}, "alic gives bobb the Vision Elixir. Their eyes gleam."},
+		{Event{Type: EventItemUsed, Source: alice, Item: "Teleport Scroll", Effect: EffectTeleport}, "alic reads the Teleport Scroll and vanishes!"},
+		{Event{Type: EventItemUsed, Source: alice, Item: "Bomb", Effect: EffectBomb}, "alic throws the Bomb!"},
+	}
+	for _, tt := range tests {
+		if got := FormatEvent(tt.event); got != tt.want {
+			t.Errorf("FormatEvent(%+v) = %q, want %q", tt.event, got, tt.want)
+		}
+	}
+}
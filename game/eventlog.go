@@ -0,0 +1,87 @@
+package game
+
+import "fmt"
+
+// FormatEvent renders an event as a line of the English text log. Events
+// that are not worth a line, such as moves, render as "".
+func FormatEvent(e Event) string {
+	switch e.Type {
+	case EventDamaged:
+		if e.Absorbed > 0 {
+			return fmt.Sprintf("%s's armor absorbs %d damage!", e.Target.Name, e.Absorbed)
+		}
+		return attackMessage(e)
+	case EventArmorBroke:
+		return fmt.Sprintf("%s's %s breaks!", e.Target.Name, e.Item)
+	case EventMonsterKilled:
+		return fmt.Sprintf("%s is defeated!", e.Target.Name)
+	case EventPlayerDefeated:
+		return fmt.Sprintf("%s has been defeated by a %s!", e.Target.Name, e.Source.Name)
+	case EventItemPickedUp:
+		return fmt.Sprintf("%s picks up the %s.", e.Source.Name, e.Item)
+	case EventFountainUsed:
+		return fmt.Sprintf("%s drinks from the fountain.", e.Source.Name)
+	case EventLevelUp:
+		return fmt.Sprintf("%s reaches level %d!", e.Source.Name, e.Level)
+	case EventAbilityUsed:
+		return abilityMessage(e)
+	case EventItemUsed:
+		return itemMessage(e)
+	case EventExitReached:
+		if e.Depth == 0 {
+			return fmt.Sprintf("%s has reached the exit! The party is victorious!", e.Source.Name)
+		}
+		return fmt.Sprintf("%s finds stairs leading down! The party descends to depth %d.", e.Source.Name, e.Depth)
+	}
+	return ""
+}
+
+func abilityMessage(e Event) string {
+	switch e.Ability {
+	case AbilityShieldBash:
+		return fmt.Sprintf("%s slams the %s with a shield!", e.Source.Name, e.Target.Name)
+	case AbilityMultiShot:
+		return fmt.Sprintf("%s looses a volley of arrows!", e.Source.Name)
+	case AbilityExtendedVision:
+		return fmt.Sprintf("%s scans the darkness ahead.", e.Source.Name)
+	case AbilityHealAlly:
+		return fmt.Sprintf("%s heals %s for %d HP.", e.Source.Name, e.Target.Name, e.Amount)
+	}
+	return ""
+}
+
+func itemMessage(e Event) string {
+	switch e.Effect {
+	case EffectHeal:
+		if e.Target != nil {
+			return fmt.Sprintf("%s gives %s the %s, restoring %d HP.", e.Source.Name, e.Target.Name, e.Item, e.Amount)
+		}
+		return fmt.Sprintf("%s drinks the %s and recovers %d HP.", e.Source.Name, e.Item, e.Amount)
+	case EffectVision:
+		if e.Target != nil {
+			return fmt.Sprintf("%s gives %s the %s. Their eyes gleam.", e.Source.Name, e.Target.Name, e.Item)
+		}
+		return fmt.Sprintf("%s drinks the %s. The darkness recedes.", e.Source.Name, e.Item)
+	case EffectTeleport:
+		return fmt.Sprintf("%s reads the %s and vanishes!", e.Source.Name, e.Item)
+	case EffectBomb:
+		return fmt.Sprintf("%s throws the %s!", e.Source.Name, e.Item)
+	}
+	return fmt.Sprintf("%s uses the %s.", e.Source.Name, e.Item)
+}
+
+func attackMessage(e Event) string {
+	target := e.Target.Name
+	if e.Target.Monster != 0 {
+		target = "the " + target
+	}
+	switch e.Kind {
+	case AttackRanged:
+		return fmt.Sprintf("%s fires an arrow at %s for %d damage!", e.Source.Name, target, e.Amount)
+	case AttackSmite:
+		return fmt.Sprintf("The %s smites %s from afar for %d damage!", e.Source.Name, target, e.Amount)
+	case AttackBlast:
+		return fmt.Sprintf("The blast hits %s for %d damage!", target, e.Amount)
+	}
+	return fmt.Sprintf("%s attacks %s for %d damage!", e.Source.Name, target, e.Amount)
+}
@@ -0,0 +1,93 @@
+package game
+
+import "dunExpo/dungeon"
+
+// ExploredMap is a bitmap of the tiles of one floor that a player has seen.
+type ExploredMap struct {
+	Width, Height int
+	Bits          []uint64
+}
+
+// NewExploredMap returns an empty map for a floor of the given size.
+func NewExploredMap(width, height int) *ExploredMap {
+	return &ExploredMap{
+		Width:  width,
+		Height: height,
+		Bits:   make([]uint64, (width*height+63)/64),
+	}
+}
+
+func (e *ExploredMap) index(p dungeon.Point) (int, bool) {
+	if p.X < 0 || p.X >= e.Width || p.Y < 0 || p.Y >= e.Height {
+		return 0, false
+	}
+	return p.Y*e.Width + p.X, true
+}
+
+// Has reports whether p has been explored.
+func (e *ExploredMap) Has(p dungeon.Point) bool {
+	i, ok := e.index(p)
+	return ok && e.Bits[i/64]&(1<<(i%64)) != 0
+}
+
+// Set marks p as explored.
+func (e *ExploredMap) Set(p dungeon.Point) {
+	if i, ok := e.index(p); ok {
+		e.Bits[i/64] |= 1 << (i % 64)
+	}
+}
+
+// Merge marks every tile explored in other as explored here too.
+func (e *ExploredMap) Merge(other *ExploredMap) {
+	for i := range e.Bits {
+		if i < len(other.Bits) {
+			e.Bits[i] |= other.Bits[i]
+		}
+	}
+}
+
+// ExploredFloor returns the player's map of the given floor, creating it on
+// first use.
+func (p *Player) ExploredFloor(depth int) *ExploredMap {
+	if p.Explored == nil {
+		p.Explored = make(map[int]*ExploredMap)
+	}
+	e, ok := p.Explored[depth]
+	if !ok {
+		e = NewExploredMap(dungeon.MapWidth, dungeon.MapHeight)
+		p.Explored[depth] = e
+	}
+	return e
+}
+
+// UpdateExploration marks everything each player can currently see as
+// explored. With Config.SharedExploration set, players standing within the
+// teamwork radius of each other also pool what they have explored.
+func (gs *GameState) UpdateExploration() {
+	ids := gs.PlayerIDs()
+	for _, id := range ids {
+		p := gs.Players[id]
+		explored := p.ExploredFloor(gs.Depth)
+		for tile := range gs.VisibleTo(p) {
+			explored.Set(tile)
+		}
+	}
+	if !gs.Config.SharedExploration {
+		return
+	}
+	for i, id := range ids {
+		p := gs.Players[id]
+		if p.Status != "playing" {
+			continue
+		}
+		for _, otherID := range ids[i+1:] {
+			other := gs.Players[otherID]
+			if other.Status != "playing" || Distance(p.Position, other.Position) > teamworkRadius {
+				continue
+			}
+			mine, theirs := p.ExploredFloor(gs.Depth), other.ExploredFloor(gs.Depth)
+			mine.Merge(theirs)
+			theirs.Merge(mine)
+		}
+	}
+}
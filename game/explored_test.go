@@ -0,0 +1,115 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"reflect"
+	"testing"
+)
+
+func exploredCount(e *ExploredMap) int {
+	n := 0
+	for y := 0; y < e.Height; y++ {
+		for x := 0; x < e.Width; x++ {
+			if e.Has(dungeon.Point{X: x, Y: y}) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Walking down a corridor longer than the player can see keeps everything
+// seen on the way, including the start once it is out of view.
+func TestExplorationAccumulates(t *testing.T) {
+	gs, marks := arena(t, "##########################################", "#@......................................e#", "##########################################")
+	p := join(gs, "alice-1", "warrior", marks['@'][0])
+	explored := p.ExploredFloor(gs.Depth)
+	if explored.Has(marks['e'][0]) {
+		t.Fatal("far end explored before the walk")
+	}
+	seen := exploredCount(explored)
+	for p.Position != marks['e'][0] {
+		gs.Step(ClientCommand{PlayerID: p.ID, Command: Command{Version: 1, Type: CmdMove, Dir: "east"}})
+		n := exploredCount(explored)
+		if n < seen {
+			t.Fatalf("explored tiles fell from %d to %d at %v", seen, n, p.Position)
+		}
+		seen = n
+	}
+	if gs.VisibleTo(p)[marks['@'][0]] {
+		t.Fatal("start still in view; the corridor is too short")
+	}
+	if !explored.Has(marks['@'][0]) {
+		t.Error("start forgotten once out of view")
+	}
+	if want := 3 * 42; seen != want {
+		t.Errorf("%d tiles explored, want the whole corridor and its walls, %d", seen, want)
+	}
+}
+
+func TestExplorationSurvivesSave(t *testing.T) {
+	gs := newParty(t, 7)
+	playTurns(gs, 0, 40)
+	loaded := roundTrip(t, gs)
+	for _, id := range gs.PlayerIDs() {
+		want, got := gs.Players[id].Explored, loaded.Players[id].Explored
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: explored map changed across a save", id)
+		}
+	}
+	playTurns(loaded, 40, 20)
+	for _, id := range gs.PlayerIDs() {
+		before, after := gs.Players[id].ExploredFloor(1), loaded.Players[id].ExploredFloor(1)
+		for i, bits := range before.Bits {
+			if after.Bits[i]&bits != bits {
+				t.Fatalf("%s: tiles explored before the save were lost", id)
+			}
+		}
+	}
+}
+
+// Players pool what they have explored only with allies within the teamwork
+// radius, and only when the room shares exploration.
+func TestSharedExploration(t *testing.T) {
+	rows := []string{
+		"####################",
+		"#a...#b...#....c...#",
+		"####################",
+	}
+	tests := []struct {
+		name   string
+		shared bool
+		// wantB and wantC say whether b and c end up with a's side of
+		// the wall explored.
+		wantB, wantC bool
+	}{
+		{"private", false, false, false},
+		{"shared", true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs, marks := arena(t, rows...)
+			gs.Config.SharedExploration = tt.shared
+			a := join(gs, "alice-1", "warrior", marks['a'][0])
+			b := join(gs, "bobby-2", "warrior", marks['b'][0])
+			c := join(gs, "carol-3", "warrior", marks['c'][0])
+			if Distance(a.Position, b.Position) > teamworkRadius || Distance(b.Position, c.Position) <= teamworkRadius {
+				t.Fatal("fixture does not straddle the teamwork radius")
+			}
+			aSide := dungeon.Point{X: 2, Y: 1}
+			if gs.VisibleTo(b)[aSide] || gs.VisibleTo(c)[aSide] {
+				t.Fatal("a's side of the wall is in sight of b or c")
+			}
+			gs.UpdateExploration()
+			if got := b.ExploredFloor(1).Has(aSide); got != tt.wantB {
+				t.Errorf("b explored a's side: %v, want %v", got, tt.wantB)
+			}
+			if got := c.ExploredFloor(1).Has(aSide); got != tt.wantC {
+				t.Errorf("c explored a's side: %v, want %v", got, tt.wantC)
+			}
+			if got := a.ExploredFloor(1).Has(marks['b'][0]); got != tt.shared {
+				t.Errorf("a explored b's side: %v, want %v", got, tt.shared)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"math/rand"
+)
+
+// DefaultMaxDepth is how many floors a run has when the room creator does not
+// choose a depth.
+const DefaultMaxDepth = 3
+
+// Floor is a single level of the dungeon. GameState keeps every floor the
+// party has visited and embeds the current one, so game logic keeps using
+// state.Dungeon, state.Monsters and friends directly.
+type Floor struct {
+	Depth         int
+	Dungeon       [][]int
+	Monsters      []*Monster
+	ExitPos       dungeon.Point
+	Spawn         dungeon.Point
+	ItemsOnGround map[dungeon.Point]*Item
+	Metrics       dungeon.Metrics
+}
+
+// NewFloor runs gen to build a validated map for the given depth and
+// populates it with monsters and items.
+func NewFloor(depth int, gen dungeon.Generator, random *rand.Rand) (*Floor, error) {
+	level, err := dungeon.Build(gen, Spawns.levelSpec(), random)
+	if err != nil {
+		return nil, err
+	}
+	monsters := SpawnMonsters(random, level, depth)
+	items := make(map[dungeon.Point]*Item)
+	for pos, name := range level.Items {
+		itemTemplate := ItemTemplates[name]
+		newItem := itemTemplate
+		items[pos] = &newItem
+	}
+	return &Floor{
+		Depth:         depth,
+		Dungeon:       level.Tiles,
+		Monsters:      monsters,
+		ExitPos:       level.Exit,
+		Spawn:         level.Spawn,
+		ItemsOnGround: items,
+		Metrics:       level.Metrics,
+	}, nil
+}
+
+// IsFinalFloor reports whether the exit on the current floor ends the run
+// rather than leading further down.
+func (gs *GameState) IsFinalFloor() bool {
+	return gs.Depth >= gs.Config.MaxDepth
+}
+
+// Descend generates the next floor, pushes it onto the stack and moves every
+// player onto its spawn point. The party stays put if the floor cannot be
+// generated.
+func (gs *GameState) Descend() error {
+	next, err := NewFloor(gs.Depth+1, gs.generator, gs.RNG)
+	if err != nil {
+		return err
+	}
+	gs.Floors = append(gs.Floors, next)
+	gs.Floor = next
+	// Players still stand where they were upstairs, so they are brought
+	// down one by one and only those already here can be in the way.
+	ids := gs.PlayerIDs()
+	players := gs.Players
+	gs.Players = make(map[string]*Player, len(players))
+	for _, id := range ids {
+		p := players[id]
+		p.Position = gs.SpawnPoint()
+		gs.Players[id] = p
+		if p.Status == "targeting" {
+			p.Status = "playing"
+		}
+		p.Target = nil
+	}
+	return nil
+}
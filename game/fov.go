@@ -0,0 +1,100 @@
+package game
+
+import "dunExpo/dungeon"
+
+// octants maps the single octant shadowcasting works in onto each of the
+// eight around the origin, as (xx, xy, yx, yy) multipliers.
+var octants = [8][4]int{
+	{1, 0, 0, 1},
+	{0, 1, 1, 0},
+	{0, -1, 1, 0},
+	{-1, 0, 0, 1},
+	{-1, 0, 0, -1},
+	{0, -1, -1, 0},
+	{0, 1, -1, 0},
+	{1, 0, 0, -1},
+}
+
+// CalculateVisibility returns the tiles visible from center within radius
+// using recursive shadowcasting: walls block sight but are themselves seen,
+// so a player never sees through rock into the next cave. The radius is
+// measured as a circle.
+func CalculateVisibility(dungeonMap [][]int, center dungeon.Point, radius int) map[dungeon.Point]bool {
+	visible := map[dungeon.Point]bool{center: true}
+	for _, o := range octants {
+		castLight(dungeonMap, visible, center, 1, 1.0, 0.0, radius, o[0], o[1], o[2], o[3])
+	}
+	return visible
+}
+
+// CanSee reports whether target is within radius of from and no wall stands
+// on the straight line between them, the line GetLineOfSightPath draws. It
+// checks a single tile far more cheaply than a full CalculateVisibility.
+func CanSee(dungeonMap [][]int, from, target dungeon.Point, radius int) bool {
+	dx, dy := target.X-from.X, target.Y-from.Y
+	if dx*dx+dy*dy > radius*radius {
+		return false
+	}
+	if from == target {
+		return true
+	}
+	line := GetLineOfSightPath(from, target)
+	for _, p := range line[1 : len(line)-1] {
+		if blocksSight(dungeonMap, p) {
+			return false
+		}
+	}
+	return true
+}
+
+func blocksSight(dungeonMap [][]int, p dungeon.Point) bool {
+	if p.Y < 0 || p.Y >= len(dungeonMap) || p.X < 0 || p.X >= len(dungeonMap[p.Y]) {
+		return true
+	}
+	return dungeonMap[p.Y][p.X] == dungeon.TileWall
+}
+
+// castLight scans one octant row by row, starting at row, between the start
+// and end slopes, recursing past each wall with the narrowed slopes.
+func castLight(dungeonMap [][]int, visible map[dungeon.Point]bool, center dungeon.Point, row int, start, end float64, radius, xx, xy, yx, yy int) {
+	if start < end {
+		return
+	}
+	radiusSq := radius * radius
+	for j := row; j <= radius; j++ {
+		dx, dy := -j-1, -j
+		blocked := false
+		newStart := 0.0
+		for dx <= 0 {
+			dx++
+			p := dungeon.Point{X: center.X + dx*xx + dy*xy, Y: center.Y + dx*yx + dy*yy}
+			leftSlope := (float64(dx) - 0.5) / (float64(dy) + 0.5)
+			rightSlope := (float64(dx) + 0.5) / (float64(dy) - 0.5)
+			if start < rightSlope {
+				continue
+			}
+			if end > leftSlope {
+				break
+			}
+			inBounds := p.Y >= 0 && p.Y < len(dungeonMap) && p.X >= 0 && p.X < len(dungeonMap[p.Y])
+			if inBounds && dx*dx+dy*dy <= radiusSq {
+				visible[p] = true
+			}
+			if blocked {
+				if blocksSight(dungeonMap, p) {
+					newStart = rightSlope
+					continue
+				}
+				blocked = false
+				start = newStart
+			} else if blocksSight(dungeonMap, p) && j < radius {
+				blocked = true
+				castLight(dungeonMap, visible, center, j+1, start, leftSlope, radius, xx, xy, yx, yy)
+				newStart = rightSlope
+			}
+		}
+		if blocked {
+			break
+		}
+	}
+}
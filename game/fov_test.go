@@ -0,0 +1,234 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"testing"
+)
+
+// fixture builds a full-size map from rows drawn in its top-left corner;
+// everything else is wall. '#' and 'W' are walls, any other rune is floor.
+// It returns the map and where each rune other than '#' and '.' appears.
+func fixture(rows ...string) ([][]int, map[rune][]dungeon.Point) {
+	tiles := make([][]int, dungeon.MapHeight)
+	for y := range tiles {
+		tiles[y] = make([]int, dungeon.MapWidth)
+	}
+	marks := map[rune][]dungeon.Point{}
+	for y, row := range rows {
+		for x, r := range row {
+			if r != '#' && r != 'W' {
+				tiles[y][x] = dungeon.TileFloor
+			}
+			if r != '#' && r != '.' {
+				marks[r] = append(marks[r], dungeon.Point{X: x, Y: y})
+			}
+		}
+	}
+	return tiles, marks
+}
+
+// In the visibility fixtures '@' is the viewer, 'v' must be visible, 'h'
+// must be hidden and 'W' is a wall that must be visible.
+func TestCalculateVisibility(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   []string
+		radius int
+	}{
+		{
+			name: "wall hides the cave behind it",
+			rows: []string{
+				"###########",
+				"#v...W.hhh#",
+				"#..@.W.hhh#",
+				"#v...W.hhh#",
+				"###########",
+			},
+			radius: 8,
+		},
+		{
+			name: "shadow behind a pillar",
+			rows: []string{
+				"v.........v",
+				"...........",
+				"@..W.hhh...",
+				"...........",
+				"v.........v",
+			},
+			radius: 11,
+		},
+		{
+			name: "radius edge",
+			rows: []string{
+				"...h...",
+				".h.v.h.",
+				"..v.v..",
+				"hv.@.vh",
+				"..v.v..",
+				".h.v.h.",
+				"...h...",
+			},
+			radius: 2,
+		},
+		{
+			name: "map border",
+			rows: []string{
+				"@vvv",
+				"vvvv",
+				"vvv#",
+			},
+			radius: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tiles, marks := fixture(tt.rows...)
+			visible := CalculateVisibility(tiles, marks['@'][0], tt.radius)
+			for _, p := range append(marks['v'], marks['W']...) {
+				if !visible[p] {
+					t.Errorf("%v should be visible", p)
+				}
+			}
+			for _, p := range marks['h'] {
+				if visible[p] {
+					t.Errorf("%v should be hidden", p)
+				}
+			}
+			for p := range visible {
+				if p.X < 0 || p.Y < 0 || p.X >= dungeon.MapWidth || p.Y >= dungeon.MapHeight {
+					t.Errorf("%v is off the map", p)
+				}
+			}
+		})
+	}
+}
+
+func TestCanSee(t *testing.T) {
+	tiles, marks := fixture(
+		"##########",
+		"#@..a.W.b#",
+		"#........#",
+		"#.....c..#",
+		"##########",
+	)
+	from := marks['@'][0]
+	tests := []struct {
+		name   string
+		target dungeon.Point
+		radius int
+		want   bool
+	}{
+		{"in the open", marks['a'][0], 6, true},
+		{"behind a wall", marks['b'][0], 8, false},
+		{"diagonal in range", marks['c'][0], 6, true},
+		{"beyond the radius", marks['c'][0], 5, false},
+		{"its own tile", from, 0, true},
+	}
+	for _, tt := range tests {
+		if got := CanSee(tiles, from, tt.target, tt.radius); got != tt.want {
+			t.Errorf("%s: CanSee = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCanShoot(t *testing.T) {
+	tiles, marks := fixture(
+		"##########",
+		"#@..a.W.b#",
+		"#........#",
+		"#.......c#",
+		"##########",
+	)
+	state := &GameState{Floor: &Floor{Dungeon: tiles}}
+	bow := &Item{Name: "Bow", IsWeapon: true, Damage: 5, Range: 6}
+	sword := &Item{Name: "Sword", IsWeapon: true, Damage: 15, Range: 1}
+	tests := []struct {
+		name   string
+		weapon *Item
+		target dungeon.Point
+		hp     int
+		want   bool
+	}{
+		{"in range and sight", bow, marks['a'][0], 10, true},
+		{"behind a wall", bow, marks['b'][0], 10, false},
+		{"out of range", bow, marks['c'][0], 10, false},
+		{"already dead", bow, marks['a'][0], 0, false},
+		{"melee weapon", sword, marks['a'][0], 10, false},
+		{"unarmed", nil, marks['a'][0], 10, false},
+	}
+	for _, tt := range tests {
+		p := &Player{ID: "shooter", Position: marks['@'][0], EquippedWeapon: tt.weapon}
+		m := &Monster{Position: tt.target, CurrentHP: tt.hp}
+		if got := CanShoot(state, p, m); got != tt.want {
+			t.Errorf("%s: CanShoot = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// A monster chases a player it can see and heads home when a wall is in the
+// way, even with the player well inside its vision radius. '@' is the player
+// it should be judged against; 'h' is another player, hidden from it.
+func TestMonsterNoticesOnlyVisiblePlayers(t *testing.T) {
+	tests := []struct {
+		name  string
+		rows  []string
+		chase bool
+	}{
+		{
+			name: "in sight",
+			rows: []string{
+				"##########",
+				"#........#",
+				"#sM...@..#",
+				"#........#",
+				"##########",
+			},
+			chase: true,
+		},
+		{
+			name: "behind a wall",
+			rows: []string{
+				"##########",
+				"#....#...#",
+				"#sM..#@..#",
+				"#....#...#",
+				"##########",
+			},
+			chase: false,
+		},
+		{
+			name: "a closer player out of sight",
+			rows: []string{
+				"#########",
+				"#..#h...#",
+				"#sM#....#",
+				"#.......#",
+				"#.......#",
+				"#.......#",
+				"#.@.....#",
+				"#########",
+			},
+			chase: true,
+		},
+	}
+	template := &MonsterTemplate{Name: "Goblin", HP: 7, Attack: 6, VisionRadius: 8, LeashRadius: 12, AttackRange: 1}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tiles, marks := fixture(tt.rows...)
+			m := &Monster{Template: template, Position: marks['M'][0], CurrentHP: 7, SpawnPoint: marks['s'][0]}
+			p := &Player{ID: "player-1", Position: marks['@'][0], Status: "playing"}
+			state := &GameState{
+				Floor:   &Floor{Dungeon: tiles, Monsters: []*Monster{m}},
+				Players: map[string]*Player{p.ID: p},
+			}
+			for _, pos := range marks['h'] {
+				state.Players["hidden-2"] = &Player{ID: "hidden-2", Position: pos, Status: "playing"}
+			}
+			before := Distance(m.Position, p.Position)
+			m.takeTurn(state)
+			if chased := Distance(m.Position, p.Position) < before; chased != tt.chase {
+				t.Errorf("monster moved to %v; chased = %v, want %v", m.Position, chased, tt.chase)
+			}
+		})
+	}
+}
@@ -1,41 +1,25 @@
 package game
 
-import "dunExpo/dungeon"
-
 type Item struct {
-	Name       string
-	Rune       rune
-	Color      string
-	IsWeapon   bool
-	IsArmor    bool
-	Damage     int
-	Range      int
-	Durability int
+	Name         string
+	Rune         rune
+	Color        string
+	IsWeapon     bool
+	IsArmor      bool
+	IsConsumable bool
+	Damage       int
+	Range        int
+	Durability   int
+	// Effect is what a consumable does; see EffectHeal and the rest. Power,
+	// Radius and Turns parameterise it.
+	Effect string `json:",omitempty"`
+	Power  int    `json:",omitempty"`
+	Radius int    `json:",omitempty"`
+	Turns  int    `json:",omitempty"`
+	// Count is how many of a consumable the stack holds.
+	Count int `json:",omitempty"`
 }
 
-var ItemTemplates = map[string]Item{
-	"sword": {
-		Name:     "Sword",
-		Rune:     '/',
-		Color:    dungeon.ColorWhite,
-		IsWeapon: true,
-		Damage:   15,
-		Range:    1,
-	},
-	"bow": {
-		Name:     "Bow",
-		Rune:     '(',
-		Color:    dungeon.ColorWhite,
-		IsWeapon: true,
-		Damage:   5,
-		Range:    6,
-	},
-	"chainmail": {
-		Name:       "Chainmail",
-		Rune:       '#',
-		Color:      dungeon.ColorWhite,
-		IsArmor:    true,
-		Durability: 20,
-	},
-	
-}
\ No newline at end of file
+// ItemTemplates holds every item by key. It is loaded from the built-in
+// content pack; see LoadContentDir.
+var ItemTemplates map[string]Item
@@ -1,10 +1,9 @@
 package game
 
 import (
-	"fmt"
 	"dunExpo/dungeon"
 	"math/rand"
-	"time"
+	"sort"
 )
 
 type MonsterTemplate struct {
@@ -17,126 +16,130 @@ type MonsterTemplate struct {
 	VisionRadius int
 	LeashRadius  int
 	AttackRange  int
-	MovingSpeed  int 
+	// AttackKind is how the monster attacks players within AttackRange:
+	// AttackMelee for monsters that must stand next to them, or AttackRanged
+	// or AttackSmite for those that strike from afar.
+	AttackKind  AttackKind
+	MovingSpeed int
+	// XP is what killing the monster is worth.
+	XP int
 }
 
-var Bestiary = map[string]MonsterTemplate{
-	"goblin": {
-		Name:         "Goblin",
-		Rune:         'g',
-		Color:        dungeon.ColorGreen,
-		HP:           7,
-		Attack:       6,
-		SpawnType:    "pack",
-		VisionRadius: 8,
-		LeashRadius:  12,
-		AttackRange:  1,
-		MovingSpeed:  2,
-	},
-	"ogre": {
-		Name:         "Ogre",
-		Rune:         'O',
-		Color:        dungeon.ColorRed,
-		HP:           25,
-		Attack:       8,
-		SpawnType:    "single",
-		VisionRadius: 6,
-		LeashRadius:  20,
-		AttackRange:  1,
-		MovingSpeed: 1,
-	},
-	"skeleton_archer": {
-		Name:         "Skeleton Archer",
-		Rune:         's',
-		Color:        dungeon.ColorWhite,
-		HP:           15,
-		Attack:       6,
-		SpawnType:    "single",
-		VisionRadius: 12,
-		LeashRadius:  10,
-		AttackRange:  6,
-		MovingSpeed: 1,
-	},
-	"bat":{
-		Name:         "Bat",
-		Rune:         'b',
-		Color:        dungeon.ColorMagenta,
-		HP:           3,
-		Attack:       2,
-		SpawnType:    "pack",
-		VisionRadius: 5,
-		LeashRadius:  8,
-		AttackRange:  1,
-		MovingSpeed: 3,
-	},
-	"guardian": {
-		Name:         "Guardian",
-		Rune:         'G', 
-		Color:        dungeon.ColorYellow, 
-		HP:           60, 
-		Attack:       18, 
-		SpawnType:    "guardian", 
-		VisionRadius: 6,  
-		LeashRadius:  15, 
-		AttackRange:  3,
-		MovingSpeed:  2,  
-	},
-	
-}
+// Bestiary holds every monster template by key. It is loaded from the
+// built-in content pack; see LoadContentDir.
+var Bestiary map[string]MonsterTemplate
 
 type Monster struct {
+	// ID identifies the monster among those on its floor.
+	ID int
+	// Kind is the Bestiary key the monster was spawned from.
+	Kind       string `json:"-"`
 	Template   *MonsterTemplate
 	Position   dungeon.Point
 	CurrentHP  int
 	SpawnPoint dungeon.Point
+	// Energy accumulates with the monster's speed and is spent on actions;
+	// see GameState.Schedule.
+	Energy int `json:"-"`
 }
 
 func (m *Monster) Move(dx, dy int, state *GameState) {
 	newPos := dungeon.Point{X: m.Position.X + dx, Y: m.Position.Y + dy}
-	if newPos.X < 0 || newPos.X >= dungeon.MapWidth {
-		return
+	if m.CanEnter(newPos, state) {
+		from := m.Position
+		m.Position = newPos
+		state.Emit(Event{Type: EventMoved, Source: m.actor(), From: &from})
 	}
-	if newPos.Y < 0 || newPos.Y >= dungeon.MapHeight {
-		return
+}
+
+// CanEnter reports whether the monster may step onto pos: monsters never walk
+// into walls, onto the exit, or onto a tile held by a player or another
+// monster.
+func (m *Monster) CanEnter(pos dungeon.Point, state *GameState) bool {
+	if pos.X < 0 || pos.X >= dungeon.MapWidth {
+		return false
 	}
-	if state.Dungeon[newPos.Y][newPos.X] == dungeon.TileWall {
-		return
+	if pos.Y < 0 || pos.Y >= dungeon.MapHeight {
+		return false
 	}
-	if newPos == state.ExitPos {
-		return
+	if state.Dungeon[pos.Y][pos.X] == dungeon.TileWall {
+		return false
+	}
+	if pos == state.ExitPos {
+		return false
 	}
 	for _, p := range state.Players {
-		if newPos == p.Position {
-			return
+		if pos == p.Position {
+			return false
 		}
 	}
 	for _, otherMonster := range state.Monsters {
-		if m != otherMonster && newPos == otherMonster.Position {
-			return
+		if m != otherMonster && pos == otherMonster.Position {
+			return false
 		}
 	}
-	m.Position = newPos
+	return true
 }
 
-func SpawnMonsters(validSpawnPoints []dungeon.Point , exitPos dungeon.Point ) []*Monster {
-	source := rand.NewSource(time.Now().UnixNano())
-	random := rand.New(source)
-
+// StepToward moves the monster one tile along the shortest route to goal.
+// It stays put when no route exists or the next tile is taken.
+func (m *Monster) StepToward(goal dungeon.Point, state *GameState) {
+	path := FindPath(state.Dungeon, m.Position, goal, func(p dungeon.Point) bool {
+		return m.CanEnter(p, state)
+	})
+	if len(path) == 0 {
+		return
+	}
+	next := path[0]
+	m.Move(next.X-m.Position.X, next.Y-m.Position.Y, state)
+}
 
-	var guardianSpawnPoint dungeon.Point
-	foundSpawn := false
-	for _, p := range validSpawnPoints {
-		if Distance(p, exitPos) <= 5 {
-			guardianSpawnPoint = p
-			foundSpawn = true
-			break
+// guardianPost picks where the exit's guardian stands: an open tile within
+// five steps of the exit, preferring tiles that are not choke points so the
+// guardian never seals off part of the level by standing still.
+func guardianPost(candidates []dungeon.Point, level *dungeon.Level) (dungeon.Point, bool) {
+	chokes := make(map[dungeon.Point]bool, len(level.Metrics.ChokePoints))
+	for _, p := range level.Metrics.ChokePoints {
+		chokes[p] = true
+	}
+	var fallback dungeon.Point
+	foundFallback := false
+	for _, p := range candidates {
+		if Distance(p, level.Exit) > 5 {
+			continue
+		}
+		if !chokes[p] {
+			return p, true
+		}
+		if !foundFallback {
+			fallback, foundFallback = p, true
 		}
 	}
-	
+	return fallback, foundFallback
+}
+
+// scaleForDepth toughens a template for deeper floors: every floor below the
+// first adds a quarter of the base HP and XP and a sixth of the base attack.
+func scaleForDepth(template MonsterTemplate, depth int) MonsterTemplate {
+	if depth <= 1 {
+		return template
+	}
+	extra := depth - 1
+	template.HP += template.HP * extra / 4
+	template.Attack += template.Attack * extra / 6
+	template.XP += template.XP * extra / 4
+	return template
+}
+
+func SpawnMonsters(random *rand.Rand, level *dungeon.Level, depth int) []*Monster {
+	validSpawnPoints := append([]dungeon.Point(nil), level.Open...)
+	guardianSpawnPoint, foundSpawn := guardianPost(validSpawnPoints, level)
+
 	var monsters []*Monster
 	if foundSpawn {
-		guardianTemplate := Bestiary["guardian"]
+		guardianTemplate := scaleForDepth(Bestiary["guardian"], depth)
 		guardian := &Monster{
+			Kind:       "guardian",
 			Template:   &guardianTemplate,
 			Position:   guardianSpawnPoint,
 			CurrentHP:  guardianTemplate.HP,
@@ -152,14 +155,15 @@ func SpawnMonsters(validSpawnPoints []dungeon.Point , exitPos dungeon.Point ) []
 		}
 		validSpawnPoints = newValidSpawns
 	}
-	totalMonstersToSpawn := 25
+	totalMonstersToSpawn := Spawns.Monsters + (depth-1)*Spawns.MonstersPerDepth
 	var monsterKeys []string
 	for k := range Bestiary {
 		monsterKeys = append(monsterKeys, k)
 	}
+	sort.Strings(monsterKeys)
 	for i := 0; i < totalMonstersToSpawn && len(validSpawnPoints) > 0; i++ {
 		randomKey := monsterKeys[random.Intn(len(monsterKeys))]
-		template := Bestiary[randomKey]
+		template := scaleForDepth(Bestiary[randomKey], depth)
 		if template.SpawnType == "guardian" {
 			continue
 
@@ -168,6 +172,7 @@ func SpawnMonsters(validSpawnPoints []dungeon.Point , exitPos dungeon.Point ) []
 		spawnPoint := validSpawnPoints[randomIndex]
 		validSpawnPoints = append(validSpawnPoints[:randomIndex], validSpawnPoints[randomIndex+1:]...)
 		newMonster := &Monster{
+			Kind:       randomKey,
 			Template:   &template,
 			Position:   spawnPoint,
 			CurrentHP:  template.HP,
@@ -184,6 +189,7 @@ func SpawnMonsters(validSpawnPoints []dungeon.Point , exitPos dungeon.Point ) []
 				packMemberSpawnPoint := validSpawnPoints[packMemberIndex]
 				validSpawnPoints = append(validSpawnPoints[:packMemberIndex], validSpawnPoints[packMemberIndex+1:]...)
 				packMonster := &Monster{
+					Kind:       randomKey,
 					Template:   &template,
 					Position:   packMemberSpawnPoint,
 					CurrentHP:  template.HP,
@@ -193,150 +199,85 @@ func SpawnMonsters(validSpawnPoints []dungeon.Point , exitPos dungeon.Point ) []
 			}
 		}
 	}
+	for i, m := range monsters {
+		m.ID = i + 1
+	}
 	return monsters
 }
 
 
 
-func UpdateMonsters(state *GameState) {
-	state.Log = []string{} 
+func UpdateMonsters(state *GameState, actorID string) {
+	for _, monster := range state.Schedule(state.Players[actorID]) {
+		if monster.CurrentHP > 0 {
+			monster.takeTurn(state)
+		}
+	}
+}
 
-	for _, monster := range state.Monsters {
+// rangedAttack reports whether the monster attacks from a distance, and how.
+func (m *Monster) rangedAttack() (AttackKind, bool) {
+	if m.Template.AttackKind == AttackMelee || m.Template.AttackRange <= 1 {
+		return "", false
+	}
+	return m.Template.AttackKind, true
+}
 
-		var closestPlayer *Player
-		minDist := -1
+// takeTurn performs one action for the monster: attack the closest player it
+// can see if it can, otherwise chase them, head home or wander. Players out
+// of its sight are ignored, however close they are.
+func (m *Monster) takeTurn(state *GameState) {
+	var closestPlayer *Player
+	minDist := -1
+	anyPlaying := false
+	sight := max(m.Template.VisionRadius, m.Template.AttackRange)
 
-		for _, player := range state.Players {
-			if player.Status != "playing" {
-				continue
-			}
-			dist := Distance(monster.Position, player.Position)
-			if minDist == -1 || dist < minDist {
-				minDist = dist
-				closestPlayer = player
-			}
+	for _, id := range state.PlayerIDs() {
+		player := state.Players[id]
+		if player.Status != "playing" {
+			continue
 		}
-
-		if closestPlayer == nil {
+		anyPlaying = true
+		dist := Distance(m.Position, player.Position)
+		if dist > 1 && !CanSee(state.Dungeon, m.Position, player.Position, sight) {
 			continue
 		}
-
-
-		if monster.Template.Name == "Guardian" {
-			distToPlayer := Distance(monster.Position, closestPlayer.Position)
-			if distToPlayer <= monster.Template.AttackRange && LineOfSight(monster.Position, closestPlayer.Position, state.Dungeon) {
-				damage := monster.Template.Attack
-				if closestPlayer.EquippedArmor != nil {
-					brokenArmor := closestPlayer.EquippedArmor
-					closestPlayer.EquippedArmor.Durability -= damage
-					state.AddMessage(fmt.Sprintf("%s's armor absorbs %d damage!", closestPlayer.ID[0:4], damage))
-					if closestPlayer.EquippedArmor.Durability <= 0 {
-						state.AddMessage(fmt.Sprintf("%s's %s breaks!", closestPlayer.ID[0:4], closestPlayer.EquippedArmor.Name))
-						closestPlayer.EquippedArmor = nil
-						var newInventory []*Item
-						for _, item := range closestPlayer.Inventory {
-							if item != brokenArmor {
-								newInventory = append(newInventory, item)
-							}
-						}
-						closestPlayer.Inventory = newInventory
-					}
-				} else {
-					closestPlayer.HP -= damage
-					state.AddMessage(fmt.Sprintf("The Guardian smites %s from afar, causing %d damage", monster.Template.Name, closestPlayer.ID[0:4], damage))
-				}
-				if closestPlayer.HP <= 0 {
-					closestPlayer.Status = "defeated"
-					state.AddMessage(fmt.Sprintf("%s has been defeated by a %s!", closestPlayer.ID[0:4], monster.Template.Name))
-				}
-				continue
-			}
+		if minDist == -1 || dist < minDist {
+			minDist = dist
+			closestPlayer = player
 		}
+	}
 
+	if !anyPlaying {
+		return
+	}
 
-		
-		if monster.Template.Name == "Skeleton Archer" {
-			distToPlayer := Distance(monster.Position, closestPlayer.Position)
-			if distToPlayer <= monster.Template.AttackRange && LineOfSight(monster.Position, closestPlayer.Position, state.Dungeon) {
-				damage := monster.Template.Attack
-				if closestPlayer.EquippedArmor != nil {
-					brokenArmor := closestPlayer.EquippedArmor
-					closestPlayer.EquippedArmor.Durability -= damage
-					state.AddMessage(fmt.Sprintf("%s's armor absorbs %d damage!", closestPlayer.ID[0:4], damage))
-					if closestPlayer.EquippedArmor.Durability <= 0 {
-						state.AddMessage(fmt.Sprintf("%s's %s breaks!", closestPlayer.ID[0:4], closestPlayer.EquippedArmor.Name))
-						closestPlayer.EquippedArmor = nil
-						var newInventory []*Item
-						for _, item := range closestPlayer.Inventory {
-							if item != brokenArmor {
-								newInventory = append(newInventory, item)
-							}
-						}
-						closestPlayer.Inventory = newInventory
-					}
-				} else {
-					closestPlayer.HP -= damage
-					state.AddMessage(fmt.Sprintf("%s fires an arrow at %s for %d damage!", monster.Template.Name, closestPlayer.ID[0:4], damage))
-				}
-				if closestPlayer.HP <= 0 {
-					closestPlayer.Status = "defeated"
-					state.AddMessage(fmt.Sprintf("%s has been defeated by a %s!", closestPlayer.ID[0:4], monster.Template.Name))
-				}
-				continue
-			}
+	distToPlayer := minDist
+	if closestPlayer != nil {
+		if kind, ok := m.rangedAttack(); ok && distToPlayer <= m.Template.AttackRange {
+			Resolve(state, m, closestPlayer, kind, Modifiers{})
+			return
 		}
-
-
-		distToPlayer := Distance(monster.Position, closestPlayer.Position)
 		if distToPlayer == 1 {
-			damage := monster.Template.Attack
-			if closestPlayer.EquippedArmor != nil {
-				brokenArmor := closestPlayer.EquippedArmor
-				closestPlayer.EquippedArmor.Durability -= damage
-				state.AddMessage(fmt.Sprintf("%s's armor absorbs %d damage!", closestPlayer.ID[0:4], damage))
-				if closestPlayer.EquippedArmor.Durability <= 0 {
-					state.AddMessage(fmt.Sprintf("%s's %s breaks!", closestPlayer.ID[0:4], closestPlayer.EquippedArmor.Name))
-					closestPlayer.EquippedArmor = nil
-					var newInventory []*Item
-					for _, item := range closestPlayer.Inventory {
-						if item != brokenArmor {
-							newInventory = append(newInventory, item)
-						}
-					}
-					closestPlayer.Inventory = newInventory
-				}
-			} else {
-				closestPlayer.HP -= damage
-				state.AddMessage(fmt.Sprintf("%s attacks %s for %d damage!", monster.Template.Name, closestPlayer.ID[0:4], damage))
-			}
-			if closestPlayer.HP <= 0 {
-				closestPlayer.Status = "defeated"
-				state.AddMessage(fmt.Sprintf("%s has been defeated by a %s!", closestPlayer.ID[0:4], monster.Template.Name))
-			}
-			continue
+			Resolve(state, m, closestPlayer, AttackMelee, Modifiers{})
+			return
 		}
+	}
 
-		distToSpawn := Distance(monster.Position, monster.SpawnPoint)
-		visionRadius := monster.Template.VisionRadius
-		leashRadius := monster.Template.LeashRadius
-		if distToPlayer <= visionRadius && distToSpawn < leashRadius {
-			dx, dy := 0, 0
-			if closestPlayer.Position.X > monster.Position.X { dx = 1 } else if closestPlayer.Position.X < monster.Position.X { dx = -1 }
-			if closestPlayer.Position.Y > monster.Position.Y { dy = 1 } else if closestPlayer.Position.Y < monster.Position.Y { dy = -1 }
-			if rand.Intn(2) == 0 { monster.Move(dx, 0, state) } else { monster.Move(0, dy, state) }
-		} else if distToSpawn > 0 {
-			dx, dy := 0, 0
-			if monster.SpawnPoint.X > monster.Position.X { dx = 1 } else if monster.SpawnPoint.X < monster.Position.X { dx = -1 }
-			if monster.SpawnPoint.Y > monster.Position.Y { dy = 1 } else if monster.SpawnPoint.Y < monster.Position.Y { dy = -1 }
-			if rand.Intn(2) == 0 { monster.Move(dx, 0, state) } else { monster.Move(0, dy, state) }
-		} else {
-			direction := rand.Intn(4)
-			switch direction {
-			case 0: monster.Move(0, -1, state); break
-			case 1: monster.Move(0, 1, state); break
-			case 2: monster.Move(-1, 0, state); break
-			case 3: monster.Move(1, 0, state); break
-			}
+	distToSpawn := Distance(m.Position, m.SpawnPoint)
+	visionRadius := m.Template.VisionRadius
+	leashRadius := m.Template.LeashRadius
+	if closestPlayer != nil && distToPlayer <= visionRadius && distToSpawn < leashRadius {
+		m.StepToward(closestPlayer.Position, state)
+	} else if distToSpawn > 0 {
+		m.StepToward(m.SpawnPoint, state)
+	} else {
+		direction := state.RNG.Intn(4)
+		switch direction {
+		case 0: m.Move(0, -1, state); break
+		case 1: m.Move(0, 1, state); break
+		case 2: m.Move(-1, 0, state); break
+		case 3: m.Move(1, 0, state); break
 		}
 	}
 }
\ No newline at end of file
@@ -0,0 +1,106 @@
+package game
+
+import (
+	"container/heap"
+	"dunExpo/dungeon"
+)
+
+// maxPathNodes bounds how many tiles one search may expand, so a monster
+// chasing an unreachable player cannot stall the turn.
+const maxPathNodes = 2000
+
+// FindPath returns the shortest 4-directional route across grid from start
+// to goal using A*. The route excludes start and ends at goal; it is nil when
+// goal cannot be reached. Walls and tiles off the grid are never entered, and
+// canEnter decides which other tiles may be stepped on. The goal itself is
+// always allowed, so a route can lead up to an occupied tile such as the
+// player being chased.
+func FindPath(grid [][]int, start, goal dungeon.Point, canEnter func(dungeon.Point) bool) []dungeon.Point {
+	if start == goal {
+		return nil
+	}
+	cameFrom := map[dungeon.Point]dungeon.Point{}
+	cost := map[dungeon.Point]int{start: 0}
+	open := &pathQueue{}
+	heap.Push(open, &pathNode{pos: start, f: Distance(start, goal)})
+	expanded := 0
+	for open.Len() > 0 && expanded < maxPathNodes {
+		current := heap.Pop(open).(*pathNode)
+		if current.pos == goal {
+			return rebuildPath(cameFrom, start, goal)
+		}
+		if current.g > cost[current.pos] {
+			continue
+		}
+		expanded++
+		for _, next := range adjacentTiles(current.pos) {
+			if next.Y < 0 || next.Y >= len(grid) || next.X < 0 || next.X >= len(grid[next.Y]) || grid[next.Y][next.X] == dungeon.TileWall {
+				continue
+			}
+			if next != goal && !canEnter(next) {
+				continue
+			}
+			g := current.g + 1
+			if known, ok := cost[next]; ok && known <= g {
+				continue
+			}
+			cost[next] = g
+			cameFrom[next] = current.pos
+			open.seq++
+			heap.Push(open, &pathNode{pos: next, g: g, f: g + Distance(next, goal), seq: open.seq})
+		}
+	}
+	return nil
+}
+
+func rebuildPath(cameFrom map[dungeon.Point]dungeon.Point, start, goal dungeon.Point) []dungeon.Point {
+	var path []dungeon.Point
+	for p := goal; p != start; p = cameFrom[p] {
+		path = append(path, p)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+func adjacentTiles(p dungeon.Point) [4]dungeon.Point {
+	return [4]dungeon.Point{
+		{X: p.X, Y: p.Y - 1},
+		{X: p.X, Y: p.Y + 1},
+		{X: p.X - 1, Y: p.Y},
+		{X: p.X + 1, Y: p.Y},
+	}
+}
+
+type pathNode struct {
+	pos  dungeon.Point
+	g, f int
+	// seq breaks ties between equally good nodes in insertion order, which
+	// keeps the chosen route identical from run to run.
+	seq int
+}
+
+type pathQueue struct {
+	nodes []*pathNode
+	seq   int
+}
+
+func (q *pathQueue) Len() int { return len(q.nodes) }
+func (q *pathQueue) Less(i, j int) bool {
+	a, b := q.nodes[i], q.nodes[j]
+	if a.f != b.f {
+		return a.f < b.f
+	}
+	if a.g != b.g {
+		return a.g > b.g
+	}
+	return a.seq < b.seq
+}
+func (q *pathQueue) Swap(i, j int)      { q.nodes[i], q.nodes[j] = q.nodes[j], q.nodes[i] }
+func (q *pathQueue) Push(x interface{}) { q.nodes = append(q.nodes, x.(*pathNode)) }
+func (q *pathQueue) Pop() interface{} {
+	last := q.nodes[len(q.nodes)-1]
+	q.nodes = q.nodes[:len(q.nodes)-1]
+	return last
+}
@@ -0,0 +1,122 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"testing"
+)
+
+// In the path fixtures 'S' is the start, 'G' the goal and 'x' a floor tile
+// canEnter refuses.
+func TestFindPath(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []string
+		// steps is the length of the shortest route, or 0 for none.
+		steps int
+	}{
+		{
+			name: "open floor",
+			rows: []string{
+				"#######",
+				"#S...G#",
+				"#######",
+			},
+			steps: 4,
+		},
+		{
+			name: "around a pillar",
+			rows: []string{
+				"#######",
+				"#.....#",
+				"#S.#.G#",
+				"#.....#",
+				"#######",
+			},
+			steps: 6,
+		},
+		{
+			name: "unreachable goal",
+			rows: []string{
+				"#######",
+				"#S..#G#",
+				"#...###",
+				"#######",
+			},
+		},
+		{
+			name: "blocked tiles are impassable",
+			rows: []string{
+				"#######",
+				"#S.x.G#",
+				"#..x..#",
+				"#..x..#",
+				"#.....#",
+				"#######",
+			},
+			steps: 10,
+		},
+		{
+			name: "blocked tiles seal the goal off",
+			rows: []string{
+				"#######",
+				"#S.x..#",
+				"#..x.G#",
+				"#..x..#",
+				"#######",
+			},
+		},
+		{
+			name: "occupied goal",
+			rows: []string{
+				"#####",
+				"#S.X#",
+				"#####",
+			},
+			steps: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tiles, marks := fixture(tt.rows...)
+			start := marks['S'][0]
+			goal := append(marks['G'], marks['X']...)[0]
+			blocked := map[dungeon.Point]bool{goal: len(marks['X']) > 0}
+			for _, p := range marks['x'] {
+				blocked[p] = true
+			}
+			path := FindPath(tiles, start, goal, func(p dungeon.Point) bool { return !blocked[p] })
+			if len(path) != tt.steps {
+				t.Fatalf("route %v has %d steps, want %d", path, len(path), tt.steps)
+			}
+			if tt.steps == 0 {
+				return
+			}
+			if path[len(path)-1] != goal {
+				t.Errorf("route %v does not end at the goal %v", path, goal)
+			}
+			prev := start
+			for _, p := range path {
+				if Distance(prev, p) != 1 || tiles[p.Y][p.X] == dungeon.TileWall || (blocked[p] && p != goal) {
+					t.Fatalf("route %v takes an illegal step to %v", path, p)
+				}
+				prev = p
+			}
+		})
+	}
+}
+
+// The search stays on the grid it is given, whatever its size.
+func TestFindPathSmallGrid(t *testing.T) {
+	tiles := [][]int{
+		{dungeon.TileFloor, dungeon.TileFloor},
+		{dungeon.TileWall, dungeon.TileFloor},
+	}
+	path := FindPath(tiles, dungeon.Point{X: 0, Y: 0}, dungeon.Point{X: 1, Y: 1}, func(dungeon.Point) bool { return true })
+	want := []dungeon.Point{{X: 1, Y: 0}, {X: 1, Y: 1}}
+	if len(path) != 2 || path[0] != want[0] || path[1] != want[1] {
+		t.Errorf("route %v, want %v", path, want)
+	}
+	if path := FindPath(tiles, dungeon.Point{X: 0, Y: 0}, dungeon.Point{X: 2, Y: 0}, func(dungeon.Point) bool { return true }); path != nil {
+		t.Errorf("found a route %v off the grid", path)
+	}
+}
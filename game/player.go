@@ -3,6 +3,7 @@ package game
 import (
 	"dunExpo/dungeon"
 	"fmt"
+	"strings"
 )
 
 type Player struct {
@@ -17,21 +18,60 @@ type Player struct {
 	EquippedArmor  *Item
 	Target         *dungeon.Point
 	VisionRadius   int
+	Speed          int
+	// Class is the Classes key the player picked, or "" for none.
+	Class string
+	// Level starts at 1 and rises as XP reaches the Levels thresholds.
+	Level int
+	XP    int
+	// AbilityCooldown is how many turns remain before the class ability can
+	// be used again.
+	AbilityCooldown int
+	// VisionBonus adds to the vision radius for VisionTurns more turns.
+	VisionBonus int
+	VisionTurns int
+	// Explored holds, per floor depth, the tiles this player has seen. It is
+	// sent to the client as remembered terrain rather than as a field.
+	Explored map[int]*ExploredMap `json:"-"`
+	// Log holds the last messages for this player, newest first. It is sent
+	// as GameStateForJSON.Log.
+	Log []string `json:"-"`
 }
 
-func NewPlayer(id string, startPos dungeon.Point) *Player {
-	return &Player{
+// NewPlayer creates a player of the given class with its stats and starting
+// gear. An unknown or empty class gets PlayerBase and no gear.
+func NewPlayer(id string, startPos dungeon.Point, class string) *Player {
+	stats := classStats(class)
+	c, ok := Classes[class]
+	if !ok {
+		class = ""
+	}
+	p := &Player{
 		ID:             id,
 		Position:       startPos,
-		HP:             100,
-		MaxHP:          100,
-		Attack:         10,
+		HP:             stats.HP,
+		MaxHP:          stats.HP,
+		Attack:         stats.Attack,
 		Status:         "playing",
 		Inventory:      []*Item{},
 		EquippedWeapon: nil,
 		EquippedArmor:  nil,
-		VisionRadius:   6,
+		VisionRadius:   stats.VisionRadius,
+		Speed:          stats.Speed,
+		Class:          class,
+		Level:          1,
+	}
+	for _, key := range c.Gear {
+		item := ItemTemplates[key]
+		p.Inventory = append(p.Inventory, &item)
+		if item.IsWeapon && p.EquippedWeapon == nil {
+			p.EquippedWeapon = p.Inventory[len(p.Inventory)-1]
+		}
+		if item.IsArmor && p.EquippedArmor == nil {
+			p.EquippedArmor = p.Inventory[len(p.Inventory)-1]
+		}
 	}
+	return p
 }
 
 func (p *Player) Move(dx, dy int, state *GameState) *Monster {
@@ -58,6 +98,36 @@ func (p *Player) Move(dx, dy int, state *GameState) *Monster {
 	return nil
 }
 
+// FindItem returns the inventory item whose name matches name, ignoring case.
+func (p *Player) FindItem(name string) *Item {
+	for _, item := range p.Inventory {
+		if strings.EqualFold(item.Name, name) {
+			return item
+		}
+	}
+	return nil
+}
+
+// removeItem takes item out of the inventory and unequips it.
+func (p *Player) removeItem(item *Item) {
+	if p.EquippedWeapon == item {
+		p.EquippedWeapon = nil
+	}
+	if p.EquippedArmor == item {
+		p.EquippedArmor = nil
+	}
+	var newInventory []*Item
+	found := false
+	for _, existing := range p.Inventory {
+		if !found && existing == item {
+			found = true
+			continue
+		}
+		newInventory = append(newInventory, existing)
+	}
+	p.Inventory = newInventory
+}
+
 func Distance(p1, p2 dungeon.Point) int {
 	dx := p1.X - p2.X
 	if dx < 0 {
@@ -70,37 +140,17 @@ func Distance(p1, p2 dungeon.Point) int {
 	return dx + dy
 }
 
-func CalculateVisibility(center dungeon.Point, radius int) map[dungeon.Point]bool {
-	visible := make(map[dungeon.Point]bool)
-	for y := center.Y - radius; y <= center.Y + radius; y++ {
-		for x := center.X - radius; x <= center.X + radius; x++ {
-			if x < 0 || x >= dungeon.MapWidth || y < 0 || y >= dungeon.MapHeight {
-				continue
-			}
-			if Distance(center, dungeon.Point{X: x, Y: y}) <= radius {
-				visible[dungeon.Point{X: x, Y: y}] = true
-			}
-		}
-	}
-	return visible
-}
-
-func ProcessPlayerCommand(playerID, command string, state *GameState) (map[string]bool, bool) {
+func ProcessPlayerCommand(playerID string, cmd Command, state *GameState) (map[string]bool, bool) {
 	playersToRemove := make(map[string]bool)
 	player, ok := state.Players[playerID]
 	if !ok || (player.Status != "playing" && player.Status != "targeting") {
 		return playersToRemove, false
 	}
 	if player.Status == "targeting" {
-		switch command {
-		case "f":
-			if target, ok := FindMonsterAt(state, player.Target); ok {
-				damage := player.EquippedWeapon.Damage
-				target.CurrentHP -= damage
-				state.AddMessage(fmt.Sprintf("%s fires an arrow at the %s for %d damage!", player.ID[0:4], target.Template.Name, damage))
-				if target.CurrentHP <= 0 {
-					state.AddMessage(fmt.Sprintf("%s is defeated!", target.Template.Name))
-				}
+		switch cmd.Type {
+		case CmdFire:
+			if target, ok := findVisibleMonster(state, player, player.Target); ok {
+				fireAt(player, target, state)
 			}
 			player.Status = "playing"
 			player.Target = nil
@@ -108,7 +158,7 @@ func ProcessPlayerCommand(playerID, command string, state *GameState) (map[strin
 		default:
 			player.Status = "playing"
 			player.Target = nil
-			state.AddMessage("Targeting cancelled.")
+			player.addMessage("Targeting cancelled.")
 			return playersToRemove, true
 		}
 	}
@@ -116,17 +166,35 @@ func ProcessPlayerCommand(playerID, command string, state *GameState) (map[strin
 	var attackedMonster *Monster
 	var dx, dy int
 	moved := false
-	switch command {
-	case "w":
-		dx, dy, moved = 0, -1, true
-	case "a":
-		dx, dy, moved = -1, 0, true
-	case "s":
-		dx, dy, moved = 0, 1, true
-	case "d":
-		dx, dy, moved = 1, 0, true
-	case "g":
+	switch cmd.Type {
+	case CmdMove:
+		step := Directions[cmd.Dir]
+		dx, dy, moved = step.X, step.Y, true
+	case CmdAttack:
+		target, ok := findVisibleMonster(state, player, cmd.Target)
+		if !ok {
+			player.addMessage("There is nothing to attack there.")
+			return playersToRemove, true
+		}
+		if Distance(player.Position, target.Position) == 1 {
+			attackedMonster = target
+		} else if player.EquippedWeapon == nil || player.EquippedWeapon.Range <= 1 {
+			player.addMessage("That target is out of reach.")
+			return playersToRemove, true
+		} else if !CanShoot(state, player, target) {
+			player.addMessage("You don't have a clear shot at that target.")
+			return playersToRemove, true
+		} else {
+			fireAt(player, target, state)
+		}
+	case CmdPickup:
 		if itemOnGround, ok := state.ItemsOnGround[player.Position]; ok {
+			if stack := player.FindItem(itemOnGround.Name); stack != nil && stack.IsConsumable {
+				stack.Count += itemOnGround.Count
+				delete(state.ItemsOnGround, player.Position)
+				state.Emit(Event{Type: EventItemPickedUp, Source: player.actor(), Item: itemOnGround.Name})
+				break
+			}
 			hasDuplicate := false
 			for _, existingItem := range player.Inventory {
 				if existingItem.Name == itemOnGround.Name {
@@ -135,7 +203,7 @@ func ProcessPlayerCommand(playerID, command string, state *GameState) (map[strin
 				}
 			}
 			if hasDuplicate {
-				state.AddMessage(fmt.Sprintf("You already have a %s.", itemOnGround.Name))
+				player.addMessage(fmt.Sprintf("You already have a %s.", itemOnGround.Name))
 				return playersToRemove, false
 			}
 			player.Inventory = append(player.Inventory, itemOnGround)
@@ -146,9 +214,26 @@ func ProcessPlayerCommand(playerID, command string, state *GameState) (map[strin
 				player.EquippedArmor = itemOnGround
 			}
 			delete(state.ItemsOnGround, player.Position)
-			state.AddMessage(fmt.Sprintf("%s picks up the %s.", player.ID[0:4], itemOnGround.Name))
+			state.Emit(Event{Type: EventItemPickedUp, Source: player.actor(), Item: itemOnGround.Name})
+		}
+	case CmdEquip:
+		if cmd.Item != "" {
+			item := player.FindItem(cmd.Item)
+			switch {
+			case item == nil:
+				player.addMessage(fmt.Sprintf("You don't have a %s.", cmd.Item))
+				return playersToRemove, true
+			case item.IsWeapon:
+				player.EquippedWeapon = item
+			case item.IsArmor:
+				player.EquippedArmor = item
+			default:
+				player.addMessage(fmt.Sprintf("The %s can't be equipped.", item.Name))
+				return playersToRemove, true
+			}
+			player.addMessage(fmt.Sprintf("%s equips the %s.", player.ID[0:4], item.Name))
+			break
 		}
-	case "e":
 		var weaponsInInventory []*Item
 		for _, item := range player.Inventory {
 			if item.IsWeapon {
@@ -169,88 +254,67 @@ func ProcessPlayerCommand(playerID, command string, state *GameState) (map[strin
 				nextIndex := (currentIndex + 1) % len(weaponsInInventory)
 				player.EquippedWeapon = weaponsInInventory[nextIndex]
 			}
-			state.AddMessage(fmt.Sprintf("%s equips the %s.", player.ID[0:4], player.EquippedWeapon.Name))
+			player.addMessage(fmt.Sprintf("%s equips the %s.", player.ID[0:4], player.EquippedWeapon.Name))
 		} else {
-			state.AddMessage("No weapons in inventory to equip.")
+			player.addMessage("No weapons in inventory to equip.")
 		}
-	case "f":
-		if player.EquippedWeapon != nil && player.EquippedWeapon.Name == "Bow" {
+	case CmdAim:
+		if player.EquippedWeapon != nil && player.EquippedWeapon.Range > 1 {
 			target := FindClosestVisibleMonster(state, player)
 			if target != nil {
 				player.Status = "targeting"
 				targetPos := target.Position
 				player.Target = &targetPos
-				state.AddMessage("Aiming... Press 'f' to fire or any other key to cancel.")
+				player.addMessage("Aiming... Fire to shoot, or do anything else to cancel.")
 				return playersToRemove, true
 			} else {
-				state.AddMessage("No valid targets in a straight line.")
+				player.addMessage("No valid targets in sight.")
 			}
 		} else {
-			state.AddMessage("You don't have a bow equipped!")
+			player.addMessage("You don't have a ranged weapon equipped!")
+		}
+	case CmdDrop:
+		droppedItem := player.EquippedWeapon
+		if cmd.Item != "" {
+			droppedItem = player.FindItem(cmd.Item)
 		}
-	case "D":
-		if player.EquippedWeapon != nil {
+		if droppedItem != nil {
 			if _, ok := state.ItemsOnGround[player.Position]; !ok {
-				droppedItem := player.EquippedWeapon
 				state.ItemsOnGround[player.Position] = droppedItem
-				player.EquippedWeapon = nil
-				var newInventory []*Item
-				found := false
-				for _, item := range player.Inventory {
-					if !found && item == droppedItem {
-						found = true
-						continue
-					}
-					newInventory = append(newInventory, item)
-				}
-				player.Inventory = newInventory
-				state.AddMessage(fmt.Sprintf("%s drops the %s.", player.ID[0:4], droppedItem.Name))
+				player.removeItem(droppedItem)
+				player.addMessage(fmt.Sprintf("%s drops the %s.", player.ID[0:4], droppedItem.Name))
 			} else {
-				state.AddMessage("You can't drop an item here.")
+				player.addMessage("You can't drop an item here.")
 			}
+		} else if cmd.Item != "" {
+			player.addMessage(fmt.Sprintf("You don't have a %s.", cmd.Item))
 		} else {
-			state.AddMessage("You have nothing equipped to drop.")
+			player.addMessage("You have nothing equipped to drop.")
 		}
+	case CmdAbility:
+		if useAbility(player, cmd, state) {
+			return playersToRemove, true
+		}
+	case CmdUse:
+		if useItem(player, cmd, state) {
+			return playersToRemove, true
+		}
+	case CmdFire, CmdCancel:
+		player.addMessage("You are not aiming at anything.")
+		return playersToRemove, true
 	}
 
 	if moved {
+		from := player.Position
 		attackedMonster = player.Move(dx, dy, state)
+		if player.Position != from {
+			state.Emit(Event{Type: EventMoved, Source: player.actor(), From: &from})
+		}
 	}
 
 	if attackedMonster != nil {
-		damage := player.Attack
-		if player.EquippedWeapon != nil {
-			damage = player.EquippedWeapon.Damage
-		}
-		attackedMonster.CurrentHP -= damage
-		state.AddMessage(fmt.Sprintf("%s attacks the %s for %d damage!", player.ID[0:4], attackedMonster.Template.Name, damage))
-		if attackedMonster.CurrentHP > 0 {
-			damage = attackedMonster.Template.Attack
-			if player.EquippedArmor != nil {
-				brokenArmor := player.EquippedArmor
-				player.EquippedArmor.Durability -= damage
-				state.AddMessage(fmt.Sprintf("%s's armor absorbs %d damage!", player.ID[0:4], damage))
-				if player.EquippedArmor.Durability <= 0 {
-					state.AddMessage(fmt.Sprintf("%s's %s breaks!", player.ID[0:4], player.EquippedArmor.Name))
-					player.EquippedArmor = nil
-					var newInventory []*Item
-					for _, item := range player.Inventory {
-						if item != brokenArmor {
-							newInventory = append(newInventory, item)
-						}
-					}
-					player.Inventory = newInventory
-				}
-			} else {
-				player.HP -= damage
-				state.AddMessage(fmt.Sprintf("%s attacks %s for %d damage!", attackedMonster.Template.Name, player.ID[0:4], damage))
-			}
-			if player.HP <= 0 {
-				player.Status = "defeated"
-				state.AddMessage(fmt.Sprintf("%s has been defeated!", player.ID[0:4]))
-			}
-		} else {
-			state.AddMessage(fmt.Sprintf("%s is defeated!", attackedMonster.Template.Name))
+		if Resolve(state, player, attackedMonster, AttackMelee, Modifiers{}).Hit && !attackedMonster.down() {
+			Resolve(state, attackedMonster, player, AttackMelee, Modifiers{})
 		}
 	}
 
@@ -265,25 +329,40 @@ func ProcessPlayerCommand(playerID, command string, state *GameState) (map[strin
 	if p, ok := state.Players[playerID]; ok && p.Status == "playing" {
 		if state.Dungeon[p.Position.Y][p.Position.X] == dungeon.TileHealth {
 			healAmount := 10
+			before := p.HP
 			p.HP += healAmount
 			if p.HP > p.MaxHP {
 				p.HP = p.MaxHP
 			}
 			state.Dungeon[p.Position.Y][p.Position.X] = dungeon.TileFloor
-			state.AddMessage(fmt.Sprintf("%s drinks from the fountain.", p.ID[0:4]))
+			state.Emit(Event{Type: EventFountainUsed, Source: p.actor(), Amount: p.HP - before})
 		}
 		if p.Position == state.ExitPos {
-			state.AddMessage(fmt.Sprintf("%s has reached the exit! The party is victorious!", p.ID[0:4]))
+			if !state.IsFinalFloor() {
+				if err := state.Descend(); err != nil {
+					p.addMessage("The stairs are blocked by rubble.")
+					return playersToRemove, false
+				}
+				state.Emit(Event{Type: EventExitReached, Source: p.actor(), Depth: state.Depth})
+				return playersToRemove, true
+			}
+			state.Emit(Event{Type: EventExitReached, Source: p.actor()})
 			for id := range state.Players {
 				playersToRemove[id] = true
 			}
 			return playersToRemove, false
 		}
 		distToExit := Distance(p.Position, state.ExitPos)
-		if distToExit <= 2 {
-			state.AddMessage("You see the exit shimmering nearby.")
+		if !state.IsFinalFloor() {
+			if distToExit <= 2 {
+				p.addMessage("You see stairs leading further down.")
+			} else if distToExit <= 5 {
+				p.addMessage("You feel a draft from stairs nearby.")
+			}
+		} else if distToExit <= 2 {
+			p.addMessage("You see the exit shimmering nearby.")
 		} else if distToExit <= 5 {
-			state.AddMessage("You feel a draft from a nearby exit.")
+			p.addMessage("You feel a draft from a nearby exit.")
 		}
 	}
 	return playersToRemove, false
@@ -327,22 +406,6 @@ func GetLineOfSightPath(p1, p2 dungeon.Point) []dungeon.Point {
 	return path
 }
 
-func LineOfSight(p1, p2 dungeon.Point, dungeonMap [][]int) bool {
-	path := GetStraightLinePath(p1, p2)
-	if len(path) == 0 {
-		return false
-	}
-	for i, p := range path {
-		if i == 0 || i == len(path)-1 {
-			continue
-		}
-		if dungeonMap[p.Y][p.X] == dungeon.TileWall {
-			return false
-		}
-	}
-	return true
-}
-
 func FindMonsterAt(state *GameState, pos *dungeon.Point) (*Monster, bool) {
 	if pos == nil {
 		return nil, false
@@ -355,45 +418,42 @@ func FindMonsterAt(state *GameState, pos *dungeon.Point) (*Monster, bool) {
 	return nil, false
 }
 
+// findVisibleMonster is FindMonsterAt for a tile the player names. A tile
+// out of their sight counts as empty, so the reply to a command aimed at it
+// is the same whether or not a monster stands there.
+func findVisibleMonster(state *GameState, p *Player, pos *dungeon.Point) (*Monster, bool) {
+	if pos == nil || !state.VisibleTo(p)[*pos] {
+		return nil, false
+	}
+	return FindMonsterAt(state, pos)
+}
+
 func FindClosestVisibleMonster(state *GameState, p *Player) *Monster {
 	var target *Monster
 	minDist := -1
 	for _, m := range state.Monsters {
-		if m.CurrentHP <= 0 {
-			continue
-		}
-		if m.Position.X != p.Position.X && m.Position.Y != p.Position.Y {
-			continue
-		}
 		dist := Distance(p.Position, m.Position)
-		if (minDist == -1 || dist < minDist) && dist <= p.EquippedWeapon.Range {
-			if LineOfSight(p.Position, m.Position, state.Dungeon) {
-				minDist = dist
-				target = m
-			}
+		if (minDist == -1 || dist < minDist) && CanShoot(state, p, m) {
+			minDist = dist
+			target = m
 		}
 	}
 	return target
 }
 
-func GetStraightLinePath(p1, p2 dungeon.Point) []dungeon.Point {
-	var path []dungeon.Point
-	x1, y1 := p1.X, p1.Y
-	x2, y2 := p2.X, p2.Y
-	if x1 == x2 {
-		if y1 > y2 {
-			y1, y2 = y2, y1
-		}
-		for y := y1; y <= y2; y++ {
-			path = append(path, dungeon.Point{X: x1, Y: y})
-		}
-	} else if y1 == y2 {
-		if x1 > x2 {
-			x1, x2 = x2, x1
-		}
-		for x := x1; x <= x2; x++ {
-			path = append(path, dungeon.Point{X: x, Y: y1})
-		}
+// CanShoot reports whether the player's equipped weapon can reach m: it must
+// be alive, within range and in the player's field of view.
+func CanShoot(state *GameState, p *Player, m *Monster) bool {
+	if p.EquippedWeapon == nil || m.CurrentHP <= 0 {
+		return false
 	}
-	return path
-}
\ No newline at end of file
+	if Distance(p.Position, m.Position) > p.EquippedWeapon.Range {
+		return false
+	}
+	return CanSee(state.Dungeon, p.Position, m.Position, p.EquippedWeapon.Range)
+}
+
+// fireAt shoots the player's ranged weapon at target.
+func fireAt(p *Player, target *Monster, state *GameState) {
+	Resolve(state, p, target, AttackRanged, Modifiers{})
+}
@@ -0,0 +1,198 @@
+package game
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ReplayVersion is the current replay file format.
+const ReplayVersion = 1
+
+// Replay event kinds, mirroring the GameState functions a session calls.
+const (
+	EventJoin    = "join"
+	EventLeave   = "leave"
+	EventReturn  = "return"
+	EventRemove  = "remove"
+	EventCommand = "command"
+	EventEnd     = "end"
+)
+
+// ReplayHeader is the first line of a replay file.
+type ReplayHeader struct {
+	Version int
+	Config  Config
+	Started time.Time
+	// Resumed is the game a session restored from a snapshot picked up
+	// from. Runs recorded from the start have none and replay from the
+	// seed in Config.
+	Resumed *SavedGame `json:",omitempty"`
+}
+
+// ReplayEvent is one line of a replay file after the header. The final
+// "end" event carries the hash of the state the run finished in.
+type ReplayEvent struct {
+	At       time.Time
+	Kind     string
+	PlayerID string `json:",omitempty"`
+	// Class is the class a player joined as.
+	Class   string   `json:",omitempty"`
+	Command *Command `json:",omitempty"`
+	Result  string   `json:",omitempty"`
+	Hash    string   `json:",omitempty"`
+}
+
+// ReplayWriter records a session as newline-delimited JSON: a header, then
+// every event in the order the session applied it.
+type ReplayWriter struct {
+	enc *json.Encoder
+}
+
+// NewReplayWriter writes the header for a run created with cfg.
+func NewReplayWriter(w io.Writer, cfg Config) (*ReplayWriter, error) {
+	return newReplayWriter(w, ReplayHeader{Version: ReplayVersion, Config: cfg, Started: time.Now().UTC()})
+}
+
+// ResumeReplayWriter writes the header for a run that carries on from gs as
+// it is now, such as a session restored from a snapshot.
+func ResumeReplayWriter(w io.Writer, gs *GameState) (*ReplayWriter, error) {
+	saved := gs.Save()
+	return newReplayWriter(w, ReplayHeader{Version: ReplayVersion, Config: gs.Config, Started: time.Now().UTC(), Resumed: &saved})
+}
+
+func newReplayWriter(w io.Writer, header ReplayHeader) (*ReplayWriter, error) {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(header); err != nil {
+		return nil, err
+	}
+	return &ReplayWriter{enc: enc}, nil
+}
+
+// Record appends an event. cmd is nil for everything but EventCommand.
+func (r *ReplayWriter) Record(kind, playerID string, cmd *Command) error {
+	return r.enc.Encode(ReplayEvent{At: time.Now().UTC(), Kind: kind, PlayerID: playerID, Command: cmd})
+}
+
+// RecordJoin appends the EventJoin of a player joining as class.
+func (r *ReplayWriter) RecordJoin(playerID, class string) error {
+	return r.enc.Encode(ReplayEvent{At: time.Now().UTC(), Kind: EventJoin, PlayerID: playerID, Class: class})
+}
+
+// End appends the final event with the result and the hash of gs.
+func (r *ReplayWriter) End(gs *GameState, result string) error {
+	hash, err := gs.Hash()
+	if err != nil {
+		return err
+	}
+	return r.enc.Encode(ReplayEvent{At: time.Now().UTC(), Kind: EventEnd, Result: result, Hash: hash})
+}
+
+// Hash fingerprints the whole game state, including the RNG position.
+func (gs *GameState) Hash() (string, error) {
+	data, err := json.Marshal(gs.Save())
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ApplyEvent re-applies a recorded event.
+func (gs *GameState) ApplyEvent(e ReplayEvent) error {
+	switch e.Kind {
+	case EventJoin:
+		gs.AddPlayer(e.PlayerID, e.Class)
+	case EventLeave:
+		gs.SetAway(e.PlayerID)
+	case EventReturn:
+		gs.SetBack(e.PlayerID)
+	case EventRemove:
+		gs.RemovePlayer(e.PlayerID)
+	case EventCommand:
+		if e.Command == nil {
+			return errors.New("command event without a command")
+		}
+		gs.Step(ClientCommand{PlayerID: e.PlayerID, Command: *e.Command})
+	default:
+		return fmt.Errorf("unknown event kind %q", e.Kind)
+	}
+	return nil
+}
+
+// ReplayResult summarises a re-simulated run.
+type ReplayResult struct {
+	Config Config
+	Events int
+	Turns  int
+	Result string
+	// Hash is the state the replay ended in and Expected the one recorded.
+	// Expected is empty when the recording stopped without an end event.
+	Hash     string
+	Expected string
+}
+
+// Matches reports whether the replay reproduced the recorded final state.
+func (r ReplayResult) Matches() bool {
+	return r.Expected != "" && r.Hash == r.Expected
+}
+
+// RunReplay re-simulates a replay file from its seed, or from the saved game
+// it resumed, and returns the final state's hash next to the recorded one.
+func RunReplay(r io.Reader) (ReplayResult, error) {
+	var result ReplayResult
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return result, err
+		}
+		return result, errors.New("empty replay")
+	}
+	var header ReplayHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return result, fmt.Errorf("reading header: %w", err)
+	}
+	if header.Version != ReplayVersion {
+		return result, fmt.Errorf("unsupported replay version %d (want %d)", header.Version, ReplayVersion)
+	}
+	var gs *GameState
+	var err error
+	if header.Resumed != nil {
+		gs, err = LoadGame(*header.Resumed)
+	} else {
+		gs, err = NewGameState(header.Config)
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Config = gs.Config
+	line := 1
+	for scanner.Scan() {
+		line++
+		var event ReplayEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return result, fmt.Errorf("line %d: %w", line, err)
+		}
+		if event.Kind == EventEnd {
+			result.Expected = event.Hash
+			result.Result = event.Result
+			break
+		}
+		if err := gs.ApplyEvent(event); err != nil {
+			return result, fmt.Errorf("line %d: %w", line, err)
+		}
+		result.Events++
+	}
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+	result.Turns = gs.Turn
+	result.Hash, err = gs.Hash()
+	return result, err
+}
@@ -0,0 +1,113 @@
+package game
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// recorder applies events to a game and records them, as a session does.
+type recorder struct {
+	t  *testing.T
+	gs *GameState
+	w  *ReplayWriter
+}
+
+func (r recorder) join(id, class string) {
+	if err := r.w.RecordJoin(id, class); err != nil {
+		r.t.Fatal(err)
+	}
+	r.gs.AddPlayer(id, class)
+}
+
+func (r recorder) apply(kind, id string, cmd *Command) {
+	if err := r.w.Record(kind, id, cmd); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := r.gs.ApplyEvent(ReplayEvent{Kind: kind, PlayerID: id, Command: cmd}); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// play records turns of the players walking a fixed pattern, with one of
+// them stepping away and back in the middle.
+func (r recorder) play(turns int) {
+	dirs := []string{"north", "east", "south", "west"}
+	ids := r.gs.PlayerIDs()
+	for i := 0; i < turns; i++ {
+		if i == turns/2 {
+			r.apply(EventLeave, ids[0], nil)
+			r.apply(EventReturn, ids[0], nil)
+		}
+		r.apply(EventCommand, ids[i%len(ids)], &Command{Version: 1, Type: CmdMove, Dir: dirs[(i/3)%len(dirs)]})
+	}
+	if err := r.w.End(r.gs, "abandoned"); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func recordRun(t *testing.T, cfg Config) (*bytes.Buffer, *GameState) {
+	gs, err := NewGameState(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := NewReplayWriter(&buf, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := recorder{t: t, gs: gs, w: w}
+	r.join("alice-1", "warrior")
+	r.join("bobby-2", "cleric")
+	r.play(60)
+	return &buf, gs
+}
+
+func TestReplayReproducesRecordedRun(t *testing.T) {
+	buf, gs := recordRun(t, Config{Seed: 42, SharedExploration: true})
+	result, err := RunReplay(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Matches() {
+		t.Fatalf("replay hashes to %s, recorded %s", result.Hash, result.Expected)
+	}
+	if result.Events != 64 || result.Turns != gs.Turn || result.Result != "abandoned" {
+		t.Errorf("got %d events, %d turns, result %q; want 64, %d, \"abandoned\"", result.Events, result.Turns, result.Result, gs.Turn)
+	}
+}
+
+func TestReplayDetectsTamperedCommands(t *testing.T) {
+	buf, _ := recordRun(t, Config{Seed: 42})
+	tampered := strings.ReplaceAll(buf.String(), `"dir":"east"`, `"dir":"west"`)
+	if tampered == buf.String() {
+		t.Fatal("no command to tamper with")
+	}
+	result, err := RunReplay(strings.NewReader(tampered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Matches() {
+		t.Error("a tampered replay still matched the recorded state")
+	}
+}
+
+// A session restored from a save records from where it resumed.
+func TestReplayOfResumedRun(t *testing.T) {
+	gs := newParty(t, 9)
+	playTurns(gs, 0, 30)
+	resumed := roundTrip(t, gs)
+	var buf bytes.Buffer
+	w, err := ResumeReplayWriter(&buf, resumed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder{t: t, gs: resumed, w: w}.play(40)
+	result, err := RunReplay(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Matches() {
+		t.Errorf("replay hashes to %s, recorded %s", result.Hash, result.Expected)
+	}
+}
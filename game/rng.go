@@ -0,0 +1,51 @@
+package game
+
+import "math/rand"
+
+// RNGState records where a session's random stream is: the seed it started
+// from and how many values have been drawn since. math/rand cannot export its
+// internal state, so restoring replays the draws from the seed.
+type RNGState struct {
+	Seed  int64
+	Draws uint64
+}
+
+// countingSource wraps the standard source and counts every value drawn.
+type countingSource struct {
+	src   rand.Source64
+	seed  int64
+	draws uint64
+}
+
+func newCountingSource(seed int64) *countingSource {
+	return &countingSource{src: rand.NewSource(seed).(rand.Source64), seed: seed}
+}
+
+func (c *countingSource) Int63() int64 {
+	c.draws++
+	return c.src.Int63()
+}
+
+func (c *countingSource) Uint64() uint64 {
+	c.draws++
+	return c.src.Uint64()
+}
+
+func (c *countingSource) Seed(seed int64) {
+	c.src.Seed(seed)
+	c.seed = seed
+	c.draws = 0
+}
+
+func (c *countingSource) state() RNGState {
+	return RNGState{Seed: c.seed, Draws: c.draws}
+}
+
+// restoreSource recreates a source at the given state.
+func restoreSource(state RNGState) *countingSource {
+	c := newCountingSource(state.Seed)
+	for c.draws < state.Draws {
+		c.Int63()
+	}
+	return c
+}
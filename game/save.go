@@ -0,0 +1,257 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"fmt"
+	"math/rand"
+)
+
+// SaveVersion is the current save format. LoadGame rejects other versions.
+const SaveVersion = 1
+
+// SavedGame is the serialisable form of a GameState. Monsters refer to their
+// Bestiary key rather than carrying a template, items on the ground are a
+// list rather than a map keyed by position, and equipped items are indexes
+// into the owner's inventory.
+type SavedGame struct {
+	Version int
+	Config  Config
+	RNG     RNGState
+	Turn    int
+	// Floors lists every floor generated so far; the party is on the last.
+	Floors  []SavedFloor
+	Players []SavedPlayer
+}
+
+type SavedFloor struct {
+	Depth         int
+	Dungeon       [][]int
+	Monsters      []SavedMonster
+	ExitPos       dungeon.Point
+	Spawn         dungeon.Point
+	ItemsOnGround []ItemOnGroundJSON
+	Metrics       dungeon.Metrics
+}
+
+type SavedMonster struct {
+	ID         int
+	Kind       string
+	Position   dungeon.Point
+	CurrentHP  int
+	SpawnPoint dungeon.Point
+	Energy     int
+}
+
+type SavedPlayer struct {
+	Player
+	// Weapon and Armor index the equipped items in Inventory, or are -1.
+	Weapon int
+	Armor  int
+	// Explored holds the bitmap of each explored floor by depth.
+	Explored map[int][]uint64
+	Log      []string
+}
+
+// Save captures the whole game so that LoadGame can resume it exactly.
+func (gs *GameState) Save() SavedGame {
+	saved := SavedGame{
+		Version: SaveVersion,
+		Config:  gs.Config,
+		RNG:     gs.source.state(),
+		Turn:    gs.Turn,
+	}
+	for _, floor := range gs.Floors {
+		saved.Floors = append(saved.Floors, saveFloor(floor))
+	}
+	for _, id := range gs.PlayerIDs() {
+		saved.Players = append(saved.Players, savePlayer(gs.Players[id]))
+	}
+	return saved
+}
+
+func saveFloor(floor *Floor) SavedFloor {
+	saved := SavedFloor{
+		Depth:         floor.Depth,
+		Dungeon:       copyTiles(nil, floor.Dungeon),
+		ExitPos:       floor.ExitPos,
+		Spawn:         floor.Spawn,
+		ItemsOnGround: sortedItems(floor.ItemsOnGround),
+		Metrics:       floor.Metrics,
+	}
+	for _, m := range floor.Monsters {
+		saved.Monsters = append(saved.Monsters, SavedMonster{
+			ID:         m.ID,
+			Kind:       m.Kind,
+			Position:   m.Position,
+			CurrentHP:  m.CurrentHP,
+			SpawnPoint: m.SpawnPoint,
+			Energy:     m.Energy,
+		})
+	}
+	return saved
+}
+
+func sortedItems(items map[dungeon.Point]*Item) []ItemOnGroundJSON {
+	list := make([]ItemOnGroundJSON, 0, len(items))
+	for y := 0; y < dungeon.MapHeight; y++ {
+		for x := 0; x < dungeon.MapWidth; x++ {
+			pos := dungeon.Point{X: x, Y: y}
+			if item, ok := items[pos]; ok {
+				itemCopy := *item
+				list = append(list, ItemOnGroundJSON{Position: pos, Item: &itemCopy})
+			}
+		}
+	}
+	return list
+}
+
+func savePlayer(p *Player) SavedPlayer {
+	saved := SavedPlayer{Player: *p, Weapon: -1, Armor: -1, Explored: map[int][]uint64{}, Log: append([]string{}, p.Log...)}
+	saved.Inventory = make([]*Item, len(p.Inventory))
+	for i, item := range p.Inventory {
+		itemCopy := *item
+		saved.Inventory[i] = &itemCopy
+		if item == p.EquippedWeapon {
+			saved.Weapon = i
+		}
+		if item == p.EquippedArmor {
+			saved.Armor = i
+		}
+	}
+	saved.EquippedWeapon, saved.EquippedArmor = nil, nil
+	if p.Target != nil {
+		target := *p.Target
+		saved.Target = &target
+	}
+	saved.Player.Explored = nil
+	for depth, explored := range p.Explored {
+		saved.Explored[depth] = append([]uint64(nil), explored.Bits...)
+	}
+	return saved
+}
+
+// LoadGame rebuilds a GameState from a save. Monster templates are looked up
+// in the current Bestiary, so a save only loads where its monsters exist.
+func LoadGame(saved SavedGame) (*GameState, error) {
+	if saved.Version != SaveVersion {
+		return nil, fmt.Errorf("unsupported save version %d (want %d)", saved.Version, SaveVersion)
+	}
+	if len(saved.Floors) == 0 {
+		return nil, fmt.Errorf("save has no floors")
+	}
+	gen, ok := dungeon.LookupGenerator(saved.Config.Generator)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownGenerator, saved.Config.Generator)
+	}
+	source := restoreSource(saved.RNG)
+	gs := &GameState{
+		Config:    saved.Config,
+		RNG:       rand.New(source),
+		source:    source,
+		generator: gen,
+		Players:   make(map[string]*Player, len(saved.Players)),
+		Turn:      saved.Turn,
+	}
+	for _, sf := range saved.Floors {
+		floor, err := loadFloor(sf)
+		if err != nil {
+			return nil, err
+		}
+		gs.Floors = append(gs.Floors, floor)
+	}
+	gs.Floor = gs.Floors[len(gs.Floors)-1]
+	for _, sp := range saved.Players {
+		p, err := loadPlayer(sp)
+		if err != nil {
+			return nil, err
+		}
+		gs.Players[p.ID] = p
+	}
+	return gs, nil
+}
+
+func loadFloor(saved SavedFloor) (*Floor, error) {
+	if len(saved.Dungeon) != dungeon.MapHeight {
+		return nil, fmt.Errorf("floor %d: map has %d rows, want %d", saved.Depth, len(saved.Dungeon), dungeon.MapHeight)
+	}
+	for y, row := range saved.Dungeon {
+		if len(row) != dungeon.MapWidth {
+			return nil, fmt.Errorf("floor %d: row %d has %d tiles, want %d", saved.Depth, y, len(row), dungeon.MapWidth)
+		}
+	}
+	floor := &Floor{
+		Depth:         saved.Depth,
+		Dungeon:       saved.Dungeon,
+		ExitPos:       saved.ExitPos,
+		Spawn:         saved.Spawn,
+		ItemsOnGround: make(map[dungeon.Point]*Item, len(saved.ItemsOnGround)),
+		Metrics:       saved.Metrics,
+	}
+	for _, item := range saved.ItemsOnGround {
+		if item.Item == nil {
+			return nil, fmt.Errorf("floor %d: empty item at %v", saved.Depth, item.Position)
+		}
+		floor.ItemsOnGround[item.Position] = item.Item
+	}
+	templates := make(map[string]*MonsterTemplate)
+	for _, sm := range saved.Monsters {
+		template, ok := templates[sm.Kind]
+		if !ok {
+			base, known := Bestiary[sm.Kind]
+			if !known {
+				return nil, fmt.Errorf("floor %d: monster %d has unknown kind %q", saved.Depth, sm.ID, sm.Kind)
+			}
+			scaled := scaleForDepth(base, saved.Depth)
+			template = &scaled
+			templates[sm.Kind] = template
+		}
+		floor.Monsters = append(floor.Monsters, &Monster{
+			ID:         sm.ID,
+			Kind:       sm.Kind,
+			Template:   template,
+			Position:   sm.Position,
+			CurrentHP:  sm.CurrentHP,
+			SpawnPoint: sm.SpawnPoint,
+			Energy:     sm.Energy,
+		})
+	}
+	return floor, nil
+}
+
+func loadPlayer(saved SavedPlayer) (*Player, error) {
+	p := saved.Player
+	p.Log = saved.Log
+	if p.Inventory == nil {
+		p.Inventory = []*Item{}
+	}
+	if p.Level == 0 {
+		// Saved before players had levels.
+		p.Level = 1
+	}
+	equipped := func(i int, what string) (*Item, error) {
+		if i == -1 {
+			return nil, nil
+		}
+		if i < 0 || i >= len(p.Inventory) {
+			return nil, fmt.Errorf("player %s: %s index %d is not in the inventory", p.ID, what, i)
+		}
+		return p.Inventory[i], nil
+	}
+	var err error
+	if p.EquippedWeapon, err = equipped(saved.Weapon, "weapon"); err != nil {
+		return nil, err
+	}
+	if p.EquippedArmor, err = equipped(saved.Armor, "armor"); err != nil {
+		return nil, err
+	}
+	p.Explored = make(map[int]*ExploredMap, len(saved.Explored))
+	for depth, bits := range saved.Explored {
+		explored := NewExploredMap(dungeon.MapWidth, dungeon.MapHeight)
+		if len(bits) != len(explored.Bits) {
+			return nil, fmt.Errorf("player %s: explored map of floor %d has the wrong size", p.ID, depth)
+		}
+		copy(explored.Bits, bits)
+		p.Explored[depth] = explored
+	}
+	return &p, nil
+}
@@ -0,0 +1,109 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newParty starts a run on seed with a warrior and a ranger.
+func newParty(t *testing.T, seed int64) *GameState {
+	t.Helper()
+	gs, err := NewGameState(Config{Seed: seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs.AddPlayer("alice-1", "warrior")
+	gs.AddPlayer("bobby-2", "ranger")
+	return gs
+}
+
+// playTurns has the players take turns walking a fixed pattern, so two runs
+// given the same state play out the same way.
+func playTurns(gs *GameState, from, turns int) {
+	dirs := []string{"north", "east", "south", "west"}
+	ids := gs.PlayerIDs()
+	for i := from; i < from+turns; i++ {
+		cmd := Command{Version: 1, Type: CmdMove, Dir: dirs[(i/3)%len(dirs)]}
+		gs.Step(ClientCommand{PlayerID: ids[i%len(ids)], Command: cmd})
+	}
+}
+
+// roundTrip saves gs through JSON, as snapshots are stored, and loads it.
+func roundTrip(t *testing.T, gs *GameState) *GameState {
+	t.Helper()
+	data, err := json.Marshal(gs.Save())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved SavedGame
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGame(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loaded
+}
+
+func mustHash(t *testing.T, gs *GameState) string {
+	t.Helper()
+	hash, err := gs.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	for _, descend := range []bool{false, true} {
+		t.Run(fmt.Sprintf("descended=%v", descend), func(t *testing.T) {
+			gs := newParty(t, 7)
+			playTurns(gs, 0, 20)
+			if descend {
+				if err := gs.Descend(); err != nil {
+					t.Fatal(err)
+				}
+				playTurns(gs, 20, 10)
+			}
+			loaded := roundTrip(t, gs)
+			if got, want := mustHash(t, loaded), mustHash(t, gs); got != want {
+				t.Fatalf("loaded state hashes to %s, saved %s", got, want)
+			}
+			if loaded.source.draws == 0 || loaded.source.draws != gs.source.draws {
+				t.Errorf("RNG restored at %d draws, saved at %d", loaded.source.draws, gs.source.draws)
+			}
+			for i, m := range gs.Monsters {
+				if !reflect.DeepEqual(loaded.Monsters[i].Template, m.Template) {
+					t.Errorf("monster %d: loaded template %+v, want %+v", m.ID, *loaded.Monsters[i].Template, *m.Template)
+				}
+			}
+
+			// Both copies play on identically, and so does a copy of the
+			// copy saved further into the run.
+			playTurns(gs, 30, 40)
+			playTurns(loaded, 30, 40)
+			if got, want := mustHash(t, loaded), mustHash(t, gs); got != want {
+				t.Fatalf("after 40 more turns the loaded game hashes to %s, the original %s", got, want)
+			}
+			again := roundTrip(t, loaded)
+			playTurns(gs, 70, 20)
+			playTurns(again, 70, 20)
+			if got, want := mustHash(t, again), mustHash(t, gs); got != want {
+				t.Errorf("reloaded game hashes to %s, the original %s", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadGameRejectsUnknownVersion(t *testing.T) {
+	saved := newParty(t, 1).Save()
+	saved.Version = SaveVersion + 1
+	_, err := LoadGame(saved)
+	if want := fmt.Sprintf("unsupported save version %d", SaveVersion+1); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got %v, want an error mentioning %q", err, want)
+	}
+}
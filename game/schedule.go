@@ -0,0 +1,54 @@
+package game
+
+// ActionCost is the energy a monster spends on one action. Monsters earn
+// energy in proportion to their speed, so a speed 3 bat acts three times for
+// every action of a speed 1 ogre. 720 divides evenly for every speed from 1
+// to 4 and up to five players, so no energy is lost to rounding; content
+// packs are held to that speed range.
+const ActionCost = 720
+
+// Schedule advances the world clock by one action of actor and returns the
+// monsters that get to act, in order. Time is shared between the active
+// players: a full round passes once each of them has acted, so adding players
+// to a room does not speed the monsters up.
+//
+// Players have no energy of their own. They act whenever they send a
+// command, and a server that held commands back until a player had saved up
+// enough energy would only feel laggy. Instead a player's speed sets how much
+// time one of their actions takes: a speed 2 player gives the monsters half
+// the time a speed 1 player would, which comes to the same ratio of actions.
+//
+// Monsters with energy for more than one action appear more than once, after
+// every other monster has had its first turn. An unknown or inactive actor
+// gives the monsters no time.
+func (gs *GameState) Schedule(actor *Player) []*Monster {
+	if actor == nil || actor.Speed <= 0 {
+		return nil
+	}
+	active := 0
+	for _, p := range gs.Players {
+		if p.Status == "playing" || p.Status == "targeting" {
+			active++
+		}
+	}
+	if active == 0 {
+		return nil
+	}
+	for _, m := range gs.Monsters {
+		m.Energy += m.Template.MovingSpeed * ActionCost / (actor.Speed * active)
+	}
+	var order []*Monster
+	for {
+		acted := false
+		for _, m := range gs.Monsters {
+			if m.Energy >= ActionCost {
+				m.Energy -= ActionCost
+				order = append(order, m)
+				acted = true
+			}
+		}
+		if !acted {
+			return order
+		}
+	}
+}
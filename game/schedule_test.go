@@ -0,0 +1,70 @@
+package game
+
+import "testing"
+
+func TestScheduleActionRatio(t *testing.T) {
+	tests := []struct {
+		name    string
+		players int
+		speed   int
+		calls   int
+	}{
+		{"one player", 1, 2, 6},
+		{"two players", 2, 2, 12},
+		{"fast player", 1, 3, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bat := &Monster{ID: 1, Template: &MonsterTemplate{Name: "Bat", MovingSpeed: 3}}
+			goblin := &Monster{ID: 2, Template: &MonsterTemplate{Name: "Goblin", MovingSpeed: 2}}
+			ogre := &Monster{ID: 3, Template: &MonsterTemplate{Name: "Ogre", MovingSpeed: 1}}
+			gs := &GameState{Floor: &Floor{Monsters: []*Monster{bat, goblin, ogre}}, Players: map[string]*Player{}}
+			var party []*Player
+			for i := 0; i < tt.players; i++ {
+				p := &Player{ID: string(rune('a' + i)), Status: "playing", Speed: tt.speed}
+				gs.Players[p.ID] = p
+				party = append(party, p)
+			}
+			actions := map[*Monster]int{}
+			for i := 0; i < tt.calls; i++ {
+				for _, m := range gs.Schedule(party[i%len(party)]) {
+					actions[m]++
+				}
+			}
+			if actions[bat] != 3*actions[ogre] || actions[goblin] != 2*actions[ogre] || actions[ogre] == 0 {
+				t.Errorf("bat %d, goblin %d, ogre %d actions; want 3:2:1", actions[bat], actions[goblin], actions[ogre])
+			}
+			for _, m := range gs.Monsters {
+				if m.Energy != 0 {
+					t.Errorf("%s has %d energy left over", m.Template.Name, m.Energy)
+				}
+			}
+		})
+	}
+}
+
+// A monster with energy for two actions takes its second after everyone
+// else has had their first.
+func TestScheduleOrder(t *testing.T) {
+	bat := &Monster{ID: 1, Template: &MonsterTemplate{Name: "Bat", MovingSpeed: 4}}
+	ogre := &Monster{ID: 2, Template: &MonsterTemplate{Name: "Ogre", MovingSpeed: 2}}
+	p := &Player{ID: "a", Status: "playing", Speed: 2}
+	gs := &GameState{Floor: &Floor{Monsters: []*Monster{bat, ogre}}, Players: map[string]*Player{p.ID: p}}
+	order := gs.Schedule(p)
+	if len(order) != 3 || order[0] != bat || order[1] != ogre || order[2] != bat {
+		t.Errorf("got %d actions in the wrong order", len(order))
+	}
+}
+
+// Players who are away or defeated, or unknown, give the monsters no time.
+func TestScheduleWithoutAnActivePlayer(t *testing.T) {
+	bat := &Monster{ID: 1, Template: &MonsterTemplate{Name: "Bat", MovingSpeed: 3}}
+	away := &Player{ID: "a", Status: "away", Speed: 2}
+	gs := &GameState{Floor: &Floor{Monsters: []*Monster{bat}}, Players: map[string]*Player{away.ID: away}}
+	if order := gs.Schedule(away); len(order) != 0 || bat.Energy != 0 {
+		t.Errorf("away player gave %d actions and %d energy", len(order), bat.Energy)
+	}
+	if order := gs.Schedule(nil); len(order) != 0 {
+		t.Errorf("unknown player gave %d actions", len(order))
+	}
+}
@@ -2,31 +2,65 @@ package game
 
 import (
 	"dunExpo/dungeon"
+	"errors"
+	"fmt"
 	"math/rand"
+	"sort"
 )
 
 // GameState is the internal, high-performance representation of the game world.
 // It uses a map for ItemsOnGround for fast lookups on the server.
 type GameState struct {
-	Dungeon       [][]int
-	Monsters      []*Monster
-	Players       map[string]*Player
-	ExitPos       dungeon.Point
-	Log           []string
-	ItemsOnGround map[dungeon.Point]*Item
+	Config Config
+	// RNG is created from Config.Seed. Every random decision in a session
+	// (map, monsters, items and AI) draws from it, so replaying the same seed
+	// and commands reproduces the same run.
+	RNG       *rand.Rand
+	source    *countingSource
+	generator dungeon.Generator
+	// Floor is the level the party is currently on; Floors holds every level
+	// generated so far, deepest last.
+	*Floor
+	Floors  []*Floor
+	Players map[string]*Player
+	// Events holds what happened in the last change to the game; see Emit.
+	Events []Event
+	// Turn counts the player commands processed so far.
+	Turn int
+}
+
+// Config holds the options a room is created with.
+type Config struct {
+	Seed int64
+	// MaxDepth is the floor whose exit wins the run.
+	MaxDepth int
+	// Generator names the dungeon.Generators entry used for every floor.
+	Generator string
+	// SharedExploration lets allies within the teamwork radius share the
+	// map they have explored.
+	SharedExploration bool
+	// SharedXP splits the XP for each kill between every player still in
+	// play instead of giving it all to the killer.
+	SharedXP bool
 }
 
 // GameStateForJSON is a "shipping manifest" used only for sending data to the client.
-// It uses a slice for items because JSON keys must be strings.
+// It uses a slice for items because JSON keys must be strings. Each player
+// gets their own, filtered by GameState.ViewFor.
 type GameStateForJSON struct {
+	Depth         int
+	MaxDepth      int
 	Dungeon       [][]int
 	Monsters      []*Monster
 	Players       map[string]*Player
-	ExitPos       dungeon.Point
+	// ExitPos is nil until the player has seen the exit.
+	ExitPos       *dungeon.Point
 	Log           []string
 	ItemsOnGround []ItemOnGroundJSON
 	HighlightedTiles []dungeon.Point 
 	VisibleTiles []dungeon.Point
+	// Events are those of the last turn the player may see.
+	Events []Event
 	PlayerTrails map[string][]dungeon.Point
 }
 
@@ -36,6 +70,92 @@ type ItemOnGroundJSON struct {
 	Item     *Item
 }
 
+// ErrUnknownGenerator is returned for a Config naming no dungeon generator.
+var ErrUnknownGenerator = errors.New("unknown dungeon generator")
+
+// NewGameState generates the first floor of a run. Zero values in cfg are
+// replaced by their defaults; an unknown generator name is an
+// ErrUnknownGenerator. It also fails when the first floor cannot be built.
+func NewGameState(cfg Config) (*GameState, error) {
+	if cfg.MaxDepth < 1 {
+		cfg.MaxDepth = DefaultMaxDepth
+	}
+	if cfg.Generator == "" {
+		cfg.Generator = dungeon.DefaultGenerator
+	}
+	gen, ok := dungeon.LookupGenerator(cfg.Generator)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownGenerator, cfg.Generator)
+	}
+	source := newCountingSource(cfg.Seed)
+	random := rand.New(source)
+	first, err := NewFloor(1, gen, random)
+	if err != nil {
+		return nil, err
+	}
+	return &GameState{
+		Config:    cfg,
+		RNG:       random,
+		source:    source,
+		generator: gen,
+		Floor:     first,
+		Floors:    []*Floor{first},
+		Players:   make(map[string]*Player),
+	}, nil
+}
+
+// PlayerIDs returns the IDs of all players in a stable order. Anything that
+// draws from RNG while walking the players must use it instead of ranging
+// over the Players map, whose order changes between runs.
+func (gs *GameState) PlayerIDs() []string {
+	ids := make([]string, 0, len(gs.Players))
+	for id := range gs.Players {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// SpawnPoint is where the next player to arrive on the floor is placed: the
+// floor's spawn tile, or else the free floor tile closest to it on foot, so a
+// party arrives together. Floors saved without a spawn tile fall back to
+// GetRandomSpawnPoint.
+func (gs *GameState) SpawnPoint() dungeon.Point {
+	if p, ok := gs.nearestFreeTile(gs.Spawn); ok {
+		return p
+	}
+	return gs.GetRandomSpawnPoint()
+}
+
+// nearestFreeTile returns from itself if it is a free tile, or else the free
+// tile closest to it on foot. It fails when from is a wall.
+func (gs *GameState) nearestFreeTile(from dungeon.Point) (dungeon.Point, bool) {
+	walkable := func(p dungeon.Point) bool {
+		return p.Y >= 0 && p.Y < len(gs.Dungeon) && p.X >= 0 && p.X < len(gs.Dungeon[p.Y]) && gs.Dungeon[p.Y][p.X] != dungeon.TileWall
+	}
+	if !walkable(from) {
+		return dungeon.Point{}, false
+	}
+	seen := map[dungeon.Point]bool{from: true}
+	queue := []dungeon.Point{from}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if freeTile(gs, p) {
+			return p, true
+		}
+		for _, next := range adjacentTiles(p) {
+			if !seen[next] && walkable(next) {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return dungeon.Point{}, false
+}
+
+// GetRandomSpawnPoint picks a random floor tile that no player or monster is
+// standing on.
 func (gs *GameState) GetRandomSpawnPoint() dungeon.Point {
 	var floorTiles []dungeon.Point
 	for y, row := range gs.Dungeon {
@@ -48,6 +168,12 @@ func (gs *GameState) GetRandomSpawnPoint() dungeon.Point {
 						break
 					}
 				}
+				for _, m := range gs.Monsters {
+					if m.Position.X == x && m.Position.Y == y {
+						isOccupied = true
+						break
+					}
+				}
 				if !isOccupied {
 					floorTiles = append(floorTiles, dungeon.Point{X: x, Y: y})
 				}
@@ -55,16 +181,24 @@ func (gs *GameState) GetRandomSpawnPoint() dungeon.Point {
 		}
 	}
 	if len(floorTiles) > 0 {
-		return floorTiles[rand.Intn(len(floorTiles))]
+		return floorTiles[gs.RNG.Intn(len(floorTiles))]
 	}
 	return dungeon.Point{}
 }
 
 const logSize = 5
 
+// AddMessage adds a message to every player's log.
 func (gs *GameState) AddMessage(message string) {
-	gs.Log = append([]string{message}, gs.Log...)
-	if len(gs.Log) > logSize {
-		gs.Log = gs.Log[:logSize]
+	for _, p := range gs.Players {
+		p.addMessage(message)
+	}
+}
+
+// addMessage puts a message at the top of the player's log.
+func (p *Player) addMessage(message string) {
+	p.Log = append([]string{message}, p.Log...)
+	if len(p.Log) > logSize {
+		p.Log = p.Log[:logSize]
 	}
 }
\ No newline at end of file
@@ -0,0 +1,61 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Players joining or descending arrive at the floor's spawn tile and never
+// land on a monster or on each other.
+func TestPlayersSpawnOnFreeTiles(t *testing.T) {
+	for seed := int64(1); seed <= 100; seed++ {
+		gs, err := NewGameState(Config{Seed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 4; i++ {
+			gs.AddPlayer(fmt.Sprintf("player-%d", i), "")
+		}
+		for floor := 1; ; floor++ {
+			if first := gs.Players["player-0"]; first.Position != gs.Spawn {
+				t.Errorf("seed %d floor %d: first player at %v, spawn is %v", seed, floor, first.Position, gs.Spawn)
+			}
+			taken := map[dungeon.Point]string{}
+			for _, id := range gs.PlayerIDs() {
+				p := gs.Players[id]
+				if gs.Dungeon[p.Position.Y][p.Position.X] != dungeon.TileFloor {
+					t.Errorf("seed %d floor %d: %s spawned on tile %d", seed, floor, id, gs.Dungeon[p.Position.Y][p.Position.X])
+				}
+				for _, m := range gs.Monsters {
+					if m.Position == p.Position {
+						t.Errorf("seed %d floor %d: %s spawned on a %s", seed, floor, id, m.Template.Name)
+					}
+				}
+				if other, ok := taken[p.Position]; ok {
+					t.Errorf("seed %d floor %d: %s spawned on %s", seed, floor, id, other)
+				}
+				taken[p.Position] = id
+			}
+			if gs.IsFinalFloor() {
+				break
+			}
+			if err := gs.Descend(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// Callers tell a bad generator name apart from a floor that failed to build.
+func TestUnknownGenerator(t *testing.T) {
+	if _, err := NewGameState(Config{Seed: 1, Generator: "maze"}); !errors.Is(err, ErrUnknownGenerator) {
+		t.Errorf("NewGameState: got %v, want ErrUnknownGenerator", err)
+	}
+	saved := newParty(t, 1).Save()
+	saved.Config.Generator = "maze"
+	if _, err := LoadGame(saved); !errors.Is(err, ErrUnknownGenerator) {
+		t.Errorf("LoadGame: got %v, want ErrUnknownGenerator", err)
+	}
+}
@@ -0,0 +1,76 @@
+package game
+
+// The functions below are the only ways a session changes the game, so that
+// a replay of them reproduces the run. Each one that can change what players
+// see ends by updating their explored maps, and each starts a fresh list of
+// events. Step also renders its events into the players' logs.
+
+// AddPlayer spawns a new player of the given class at the floor's spawn
+// point.
+func (gs *GameState) AddPlayer(id, class string) *Player {
+	gs.Events = nil
+	p := NewPlayer(id, gs.SpawnPoint(), class)
+	gs.Players[id] = p
+	gs.UpdateExploration()
+	return p
+}
+
+// RemovePlayer takes a player out of the game.
+func (gs *GameState) RemovePlayer(id string) {
+	gs.Events = nil
+	delete(gs.Players, id)
+}
+
+// SetAway marks a disconnected player as away: they cannot act and monsters
+// ignore them. Defeated players stay defeated.
+func (gs *GameState) SetAway(id string) {
+	gs.Events = nil
+	p, ok := gs.Players[id]
+	if !ok {
+		return
+	}
+	if p.Status == "playing" || p.Status == "targeting" {
+		p.Status = "away"
+		p.Target = nil
+	}
+}
+
+// SetBack returns an away player to play.
+func (gs *GameState) SetBack(id string) {
+	gs.Events = nil
+	if p, ok := gs.Players[id]; ok && p.Status == "away" {
+		p.Status = "playing"
+		gs.UpdateExploration()
+	}
+}
+
+// Step applies one player command and lets the monsters respond. It returns
+// the players who reached the final exit.
+func (gs *GameState) Step(cmd ClientCommand) map[string]bool {
+	gs.Events = nil
+	playersWhoWon, endTurnEarly := ProcessPlayerCommand(cmd.PlayerID, cmd.Command, gs)
+	if !endTurnEarly {
+		UpdateMonsters(gs, cmd.PlayerID)
+		if p, ok := gs.Players[cmd.PlayerID]; ok {
+			p.endTurn()
+		}
+	}
+	gs.Turn++
+	gs.UpdateExploration()
+	gs.logEvents()
+	if gs.AllDefeated() {
+		gs.AddMessage("All players have been defeated! The dungeon claims its victims.")
+	}
+	return playersWhoWon
+}
+
+// AllDefeated reports whether every player in the game has been defeated.
+// Away players still count as alive.
+func (gs *GameState) AllDefeated() bool {
+	for _, p := range gs.Players {
+		if p.Status == "playing" || p.Status == "targeting" || p.Status == "away" {
+			return false
+		}
+	}
+	return len(gs.Players) > 0
+}
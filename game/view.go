@@ -0,0 +1,122 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"sort"
+)
+
+// TileUnknown replaces the tiles a player has never seen in their view of
+// the map.
+const TileUnknown = -1
+
+const (
+	teamworkRadius       = 8
+	teamworkBonusPerAlly = 2
+)
+
+// EffectiveVision is the player's vision radius including any ability boost
+// and the teamwork bonus for allies standing nearby.
+func (gs *GameState) EffectiveVision(player *Player) int {
+	nearbyAllyCount := 0
+	if player.Status == "playing" {
+		for otherPlayerID, otherPlayer := range gs.Players {
+			if player.ID != otherPlayerID && otherPlayer.Status == "playing" {
+				if Distance(player.Position, otherPlayer.Position) <= teamworkRadius {
+					nearbyAllyCount++
+				}
+			}
+		}
+	}
+	return player.VisionRadius + player.VisionBonus + (nearbyAllyCount * teamworkBonusPerAlly)
+}
+
+// VisibleTo returns the tiles the player can see right now. Commands that
+// name a tile must only learn what is on it from this set, or a client could
+// probe the fog of war for monsters.
+func (gs *GameState) VisibleTo(player *Player) map[dungeon.Point]bool {
+	return CalculateVisibility(gs.Dungeon, player.Position, gs.EffectiveVision(player))
+}
+
+// ViewFor builds the snapshot sent to one player. It reveals only what that
+// player is entitled to know: map tiles they have explored (the rest are
+// TileUnknown), monsters and items currently in view, the exit once
+// explored, no other player's inventory or gear, and their own log. Explored
+// tiles missing from VisibleTiles are remembered terrain. The explored maps
+// are brought up to date by every change in step.go, so they include what
+// players see now.
+func (gs *GameState) ViewFor(playerID string) (GameStateForJSON, bool) {
+	player, ok := gs.Players[playerID]
+	if !ok {
+		return GameStateForJSON{}, false
+	}
+	explored := player.ExploredFloor(gs.Depth)
+	visibleTilesMap := gs.VisibleTo(player)
+	visibleForJSON := []dungeon.Point{}
+	for p := range visibleTilesMap {
+		visibleForJSON = append(visibleForJSON, p)
+	}
+	sort.Slice(visibleForJSON, func(i, j int) bool {
+		a, b := visibleForJSON[i], visibleForJSON[j]
+		return a.Y < b.Y || (a.Y == b.Y && a.X < b.X)
+	})
+
+	known := make([][]int, len(gs.Dungeon))
+	for y, row := range gs.Dungeon {
+		known[y] = make([]int, len(row))
+		for x, tile := range row {
+			if explored.Has(dungeon.Point{X: x, Y: y}) {
+				known[y][x] = tile
+			} else {
+				known[y][x] = TileUnknown
+			}
+		}
+	}
+	monsters := []*Monster{}
+	for _, m := range gs.Monsters {
+		if visibleTilesMap[m.Position] {
+			monsters = append(monsters, m)
+		}
+	}
+	itemsForJSON := []ItemOnGroundJSON{}
+	for pos, item := range gs.ItemsOnGround {
+		if visibleTilesMap[pos] {
+			itemsForJSON = append(itemsForJSON, ItemOnGroundJSON{Position: pos, Item: item})
+		}
+	}
+	players := make(map[string]*Player, len(gs.Players))
+	for id, p := range gs.Players {
+		if id == playerID {
+			players[id] = p
+			continue
+		}
+		public := *p
+		public.Inventory = nil
+		public.EquippedWeapon = nil
+		public.EquippedArmor = nil
+		public.Target = nil
+		players[id] = &public
+	}
+	var exitPos *dungeon.Point
+	if explored.Has(gs.ExitPos) {
+		exit := gs.ExitPos
+		exitPos = &exit
+	}
+	events := gs.eventsFor(playerID, visibleTilesMap)
+	highlighted := []dungeon.Point{}
+	if player.Status == "targeting" && player.Target != nil {
+		highlighted = GetLineOfSightPath(player.Position, *player.Target)
+	}
+	return GameStateForJSON{
+		Depth:            gs.Depth,
+		MaxDepth:         gs.Config.MaxDepth,
+		Dungeon:          known,
+		Monsters:         monsters,
+		Players:          players,
+		ExitPos:          exitPos,
+		Log:              player.Log,
+		ItemsOnGround:    itemsForJSON,
+		HighlightedTiles: highlighted,
+		VisibleTiles:     visibleForJSON,
+		Events:           events,
+	}, true
+}
@@ -0,0 +1,105 @@
+package game
+
+import (
+	"dunExpo/dungeon"
+	"strings"
+	"testing"
+)
+
+// A fight behind a wall, and the messages meant for the fighter, stay out of
+// the other player's view, as does the fighter's gear.
+func TestViewForHidesOtherPlayersBusiness(t *testing.T) {
+	gs, err := NewGameState(Config{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles, marks := fixture(
+		"############",
+		"#@g.#......#",
+		"#...#....B.#",
+		"############",
+	)
+	gs.Dungeon = tiles
+	gs.ExitPos = dungeon.Point{}
+	gs.ItemsOnGround = map[dungeon.Point]*Item{}
+	gs.Monsters = []*Monster{{ID: 1, Template: &MonsterTemplate{Name: "Goblin", HP: 7, Attack: 6, VisionRadius: 8, LeashRadius: 12, AttackRange: 1, MovingSpeed: 2}, Position: marks['g'][0], CurrentHP: 7, SpawnPoint: marks['g'][0]}}
+	fighter := gs.AddPlayer("fighter-1", "warrior")
+	fighter.Position = marks['@'][0]
+	watcher := gs.AddPlayer("watcher-2", "")
+	watcher.Position = marks['B'][0]
+	gs.UpdateExploration()
+
+	gs.Step(ClientCommand{PlayerID: fighter.ID, Command: Command{Version: 1, Type: CmdUse, Item: "Bomb"}})
+	gs.Step(ClientCommand{PlayerID: fighter.ID, Command: Command{Version: 1, Type: CmdMove, Dir: "east"}})
+	if len(gs.Monsters) != 0 {
+		t.Fatalf("the goblin should be dead, monsters left: %d", len(gs.Monsters))
+	}
+
+	mine, _ := gs.ViewFor(fighter.ID)
+	if log := strings.Join(mine.Log, "\n"); !strings.Contains(log, "Goblin") || !strings.Contains(log, "don't have a Bomb") {
+		t.Errorf("fighter's log misses their own turn:\n%s", log)
+	}
+	theirs, _ := gs.ViewFor(watcher.ID)
+	if len(theirs.Log) != 0 {
+		t.Errorf("watcher's log should be empty:\n%s", strings.Join(theirs.Log, "\n"))
+	}
+	if len(theirs.Events) != 0 {
+		t.Errorf("watcher was sent %d events", len(theirs.Events))
+	}
+	public := theirs.Players[fighter.ID]
+	if public.Inventory != nil || public.EquippedWeapon != nil || public.EquippedArmor != nil {
+		t.Errorf("fighter's gear was sent to the watcher: %+v", public)
+	}
+}
+
+// Commands aimed at a tile out of sight get the same reply whether or not a
+// monster stands there, so a client cannot sweep the map for monsters.
+func TestCommandsRevealNothingOutOfSight(t *testing.T) {
+	gs, err := NewGameState(Config{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles, marks := fixture(
+		"###########",
+		"#..@#.g.e.#",
+		"#...#.....#",
+		"###########",
+	)
+	gs.Dungeon = tiles
+	gs.ExitPos = dungeon.Point{}
+	gs.ItemsOnGround = map[dungeon.Point]*Item{}
+	goblin := &Monster{ID: 1, Template: &MonsterTemplate{Name: "Goblin", HP: 7, Attack: 6, VisionRadius: 8, LeashRadius: 12, AttackRange: 1, MovingSpeed: 2}, Position: marks['g'][0], CurrentHP: 7, SpawnPoint: marks['g'][0]}
+	gs.Monsters = []*Monster{goblin}
+	p := gs.AddPlayer("prober-1", "ranger")
+	p.Position = marks['@'][0]
+	gs.UpdateExploration()
+	if gs.VisibleTo(p)[goblin.Position] {
+		t.Fatal("the goblin should be out of sight")
+	}
+
+	reply := func(cmd Command) string {
+		p.Log = nil
+		gs.Step(ClientCommand{PlayerID: p.ID, Command: cmd})
+		return strings.Join(p.Log, "\n")
+	}
+	monster, empty := marks['g'][0], marks['e'][0]
+	if a, b := reply(Command{Version: 1, Type: CmdAttack, Target: &monster}), reply(Command{Version: 1, Type: CmdAttack, Target: &empty}); a != b {
+		t.Errorf("attacking an unseen monster replied %q, an empty tile %q", a, b)
+	}
+
+	// Having explored the far room, the player can teleport into it even
+	// onto the goblin's tile, and lands beside it.
+	explored := p.ExploredFloor(gs.Depth)
+	for x := 5; x <= 9; x++ {
+		explored.Set(dungeon.Point{X: x, Y: 1})
+	}
+	scroll := ItemTemplates["teleport_scroll"]
+	p.Inventory = append(p.Inventory, &scroll)
+	gs.Step(ClientCommand{PlayerID: p.ID, Command: Command{Version: 1, Type: CmdUse, Item: scroll.Name, Target: &monster}})
+	if p.FindItem(scroll.Name) != nil {
+		t.Fatal("the scroll was not used")
+	}
+	if p.Position == goblin.Position || Distance(p.Position, monster) != 1 {
+		t.Errorf("teleported to %v, want a tile beside %v", p.Position, monster)
+	}
+}
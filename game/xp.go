@@ -0,0 +1,77 @@
+package game
+
+// LevelTable controls levelling: how much XP each level takes and what a
+// player gains on reaching it.
+type LevelTable struct {
+	// Thresholds holds the total XP needed for level 2, 3 and so on; its
+	// length caps the level a player can reach.
+	Thresholds []int `json:"thresholds"`
+	// HP, Attack and VisionRadius are added to MaxHP, Attack and
+	// VisionRadius on every level up. The HP gained is healed as well, and
+	// the attack gained is added to weapon damage.
+	HP           int `json:"hp"`
+	Attack       int `json:"attack"`
+	VisionRadius int `json:"visionRadius"`
+}
+
+// Levels holds the active level table. Like PlayerBase it comes from the
+// built-in content pack and can be overridden with LoadContentDir.
+var Levels LevelTable
+
+// MaxLevel is the highest level the level table allows.
+func MaxLevel() int {
+	return len(Levels.Thresholds) + 1
+}
+
+// NextLevelXP is the total XP a player at level needs for the next level, or
+// 0 at the maximum level.
+func NextLevelXP(level int) int {
+	if level < 1 || level > len(Levels.Thresholds) {
+		return 0
+	}
+	return Levels.Thresholds[level-1]
+}
+
+// awardXP hands out the XP for killing m. With Config.SharedXP every player
+// still in play gets an even share and the killer any remainder; otherwise
+// the killer gets it all.
+func (gs *GameState) awardXP(killer *Player, m *Monster) {
+	xp := m.Template.XP
+	if xp <= 0 {
+		return
+	}
+	recipients := []*Player{killer}
+	if gs.Config.SharedXP {
+		recipients = recipients[:0]
+		for _, id := range gs.PlayerIDs() {
+			p := gs.Players[id]
+			if p == killer || p.Status == "playing" || p.Status == "targeting" {
+				recipients = append(recipients, p)
+			}
+		}
+	}
+	share := xp / len(recipients)
+	for _, p := range recipients {
+		gained := share
+		if p == killer {
+			gained += xp - share*len(recipients)
+		}
+		if gained > 0 {
+			gs.gainXP(p, gained)
+		}
+	}
+}
+
+// gainXP adds XP to a player and levels them up as many times as it takes.
+func (gs *GameState) gainXP(p *Player, xp int) {
+	p.XP += xp
+	gs.Emit(Event{Type: EventXPGained, Source: p.actor(), Amount: xp})
+	for next := NextLevelXP(p.Level); next > 0 && p.XP >= next; next = NextLevelXP(p.Level) {
+		p.Level++
+		p.MaxHP += Levels.HP
+		p.HP += Levels.HP
+		p.Attack += Levels.Attack
+		p.VisionRadius += Levels.VisionRadius
+		gs.Emit(Event{Type: EventLevelUp, Source: p.actor(), Level: p.Level})
+	}
+}
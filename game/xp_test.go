@@ -0,0 +1,105 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLevelThresholds(t *testing.T) {
+	tests := []struct {
+		xp, level int
+	}{
+		{0, 1},
+		{29, 1},
+		{30, 2},
+		{79, 2},
+		{80, 3},
+		{449, 5},
+		{450, 6},
+		{10000, 6},
+	}
+	for _, tt := range tests {
+		gs, marks := arena(t, "#####", "#@..#", "#####")
+		p := join(gs, "alice-1", "warrior", marks['@'][0])
+		if tt.xp > 0 {
+			gs.gainXP(p, tt.xp)
+		}
+		if p.Level != tt.level {
+			t.Errorf("%d XP: level %d, want %d", tt.xp, p.Level, tt.level)
+		}
+	}
+	if NextLevelXP(1) != 30 || NextLevelXP(5) != 450 || NextLevelXP(MaxLevel()) != 0 {
+		t.Errorf("NextLevelXP: %d, %d, %d", NextLevelXP(1), NextLevelXP(5), NextLevelXP(MaxLevel()))
+	}
+}
+
+// Crossing two thresholds at once grants both levels, each with its stats.
+func TestLevelStatGrowth(t *testing.T) {
+	gs, marks := arena(t, "#####", "#@..#", "#####")
+	p := join(gs, "alice-1", "warrior", marks['@'][0])
+	base := Classes["warrior"].Stats
+	p.HP = 50
+	gs.gainXP(p, 85)
+	if p.Level != 3 {
+		t.Fatalf("level %d, want 3", p.Level)
+	}
+	if p.MaxHP != base.HP+2*Levels.HP || p.HP != 50+2*Levels.HP {
+		t.Errorf("HP %d/%d, want %d/%d", p.HP, p.MaxHP, 50+2*Levels.HP, base.HP+2*Levels.HP)
+	}
+	if p.Attack != base.Attack+2*Levels.Attack {
+		t.Errorf("attack %d, want %d", p.Attack, base.Attack+2*Levels.Attack)
+	}
+	if p.VisionRadius != base.VisionRadius+2*Levels.VisionRadius {
+		t.Errorf("vision %d, want %d", p.VisionRadius, base.VisionRadius+2*Levels.VisionRadius)
+	}
+	want := []EventType{EventXPGained, EventLevelUp, EventLevelUp}
+	if got := eventTypes(gs.Events); !reflect.DeepEqual(got, want) {
+		t.Errorf("events %v, want %v", got, want)
+	}
+}
+
+// The attack from a level counts once, armed or not, and survives a save.
+func TestAttackAtLevelTwo(t *testing.T) {
+	gs, marks := arena(t, "#####", "#@..#", "#####")
+	p := join(gs, "alice-1", "warrior", marks['@'][0])
+	sword := p.EquippedWeapon.Damage
+	gs.gainXP(p, NextLevelXP(1))
+	if got, want := p.AttackPower(), sword+Levels.Attack; got != want {
+		t.Errorf("armed attack %d at level 2, want %d", got, want)
+	}
+	if got := roundTrip(t, gs).Players[p.ID].AttackPower(); got != sword+Levels.Attack {
+		t.Errorf("armed attack %d after a reload, want %d", got, sword+Levels.Attack)
+	}
+	p.EquippedWeapon = nil
+	if got, want := p.AttackPower(), Classes["warrior"].Stats.Attack+Levels.Attack; got != want {
+		t.Errorf("unarmed attack %d at level 2, want %d", got, want)
+	}
+}
+
+func TestKillXP(t *testing.T) {
+	tests := []struct {
+		name   string
+		shared bool
+		want   map[string]int
+	}{
+		{"killer only", false, map[string]int{"alice-1": 10, "bobby-2": 0, "carol-3": 0, "dave-4": 0}},
+		{"shared", true, map[string]int{"alice-1": 4, "bobby-2": 3, "carol-3": 3, "dave-4": 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs, marks := arena(t, "#######", "#@g.bc#", "#d....#", "#######")
+			gs.Config.SharedXP = tt.shared
+			killer := join(gs, "alice-1", "warrior", marks['@'][0])
+			join(gs, "bobby-2", "ranger", marks['b'][0])
+			join(gs, "carol-3", "cleric", marks['c'][0])
+			join(gs, "dave-4", "scout", marks['d'][0]).Status = "defeated"
+			spawn(gs, marks['g'][0], 1, 6, 10)
+			ProcessPlayerCommand(killer.ID, Command{Version: 1, Type: CmdMove, Dir: "east"}, gs)
+			for id, xp := range tt.want {
+				if got := gs.Players[id].XP; got != xp {
+					t.Errorf("%s has %d XP, want %d", id, got, xp)
+				}
+			}
+		})
+	}
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
-	"dunExpo/dungeon"
+	"context"
 	"dunExpo/game"
+	"errors"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,6 +30,21 @@ var upgrader = websocket.Upgrader{
 type InitialMessage struct {
 	Type string `json:"type"`
 	Code string `json:"code,omitempty"`
+	Seed int64  `json:"seed,omitempty"`
+	// Depth is the number of floors the party must clear; zero means
+	// game.DefaultMaxDepth.
+	Depth int `json:"depth,omitempty"`
+	// Generator picks a dungeon.Generators entry; empty means the default.
+	Generator string `json:"generator,omitempty"`
+	// SharedMap lets nearby allies pool the map they have explored.
+	SharedMap bool `json:"sharedMap,omitempty"`
+	// SharedXP splits kill XP between the whole party.
+	SharedXP bool `json:"sharedXP,omitempty"`
+	// Token is the reconnect token from "welcome", sent with "resume".
+	Token string `json:"token,omitempty"`
+	// Class is the game.Classes key to play as with "create" or "join";
+	// empty means no class.
+	Class string `json:"class,omitempty"`
 }
 
 type ServerResponse struct {
@@ -35,18 +53,24 @@ type ServerResponse struct {
 	ID      string `json:"id,omitempty"`
 	Code    string `json:"code,omitempty"`
 	Result  string `json:"result,omitempty"`
+	Seed    int64  `json:"seed,omitempty"`
+	Token   string `json:"token,omitempty"`
 }
 
+// maxRoomDepth caps the depth a room creator may ask for.
+const maxRoomDepth = 20
+
 type Server struct {
 	Sessions map[string]*Session
 	mux      sync.Mutex
 	cleanup  chan string
-}
-
-type Client struct {
-	Conn       *websocket.Conn
-	PlayerID   string
-	CmdChannel chan<- game.ClientCommand
+	Config   ServerConfig
+	// closing is set once shutdown begins; no rooms are created or joined
+	// after that.
+	closing bool
+	// ended holds sessions cleaned up while players were still connected,
+	// so that Shutdown can tell them too.
+	ended []*Session
 }
 
 type Session struct {
@@ -57,6 +81,21 @@ type Session struct {
 	CommandStream chan game.ClientCommand
 	IsOver        bool
 	cleanup       chan<- string
+	// tokens maps reconnect tokens to player IDs and held holds the grace
+	// timers of disconnected players; both are guarded by mux. done is
+	// closed when RunLoop ends.
+	tokens   map[string]string
+	held     map[string]*time.Timer
+	settings ServerConfig
+	done     chan struct{}
+	// closingClients are the connections being drained by a shutdown, and
+	// notified is set once they have been told; both are guarded by mux.
+	closingClients []*Client
+	notified       bool
+	// replay records the session when the server keeps replays; see
+	// record. Guarded by mux.
+	replay     *game.ReplayWriter
+	replayFile *os.File
 }
 
 func (s *Session) BroadcastGameOver(result string) {
@@ -67,7 +106,7 @@ func (s *Session) BroadcastGameOver(result string) {
 		Result: result,
 	}
 	for _, client := range s.Clients {
-		client.Conn.WriteJSON(gameOverMsg)
+		client.Send(gameOverMsg)
 	}
 }
 func generateRoomCode() string {
@@ -80,42 +119,60 @@ func generateRoomCode() string {
 	return string(b)
 }
 
-func NewSession(code string, cleanup chan<- string) *Session {
-	dungeonMap, floorTiles, _, endPos, itemLocations := dungeon.GenerateDungeon(dungeon.MapWidth, dungeon.MapHeight)
-	monsters := game.SpawnMonsters(floorTiles,endPos)
-	items := make(map[dungeon.Point]*game.Item)
-	for pos, name := range itemLocations {
-		itemTemplate := game.ItemTemplates[name]
-		newItem := itemTemplate
-		items[pos] = &newItem
-	}
-	gs := game.GameState{
-		Dungeon:       dungeonMap,
-		Monsters:      monsters,
-		Players:       make(map[string]*game.Player),
-		ExitPos:       endPos,
-		ItemsOnGround: items,
+// generateSeed picks a seed for sessions created without one.
+func generateSeed() int64 {
+	return time.Now().UnixNano()
+}
+
+func NewSession(code string, cfg game.Config, settings ServerConfig, cleanup chan<- string) (*Session, error) {
+	gs, err := game.NewGameState(cfg)
+	if err != nil {
+		return nil, err
 	}
-	return &Session{
+	s := &Session{
 		Code:          code,
-		GameState:     gs,
+		GameState:     *gs,
 		Clients:       make(map[string]*Client),
 		CommandStream: make(chan game.ClientCommand, 100),
 		IsOver:        false,
 		cleanup:       cleanup,
+		tokens:        make(map[string]string),
+		held:          make(map[string]*time.Timer),
+		settings:      settings,
+		done:          make(chan struct{}),
+	}
+	if settings.ReplayDir != "" {
+		s.startReplay(settings.ReplayDir, false)
 	}
+	return s, nil
 }
 
 func NewServer() *Server {
 	return &Server{
 		Sessions: make(map[string]*Session),
 		cleanup:  make(chan string, 10),
+		Config: ServerConfig{
+			ReconnectGrace:  DefaultReconnectGrace,
+			Conn:            DefaultConnConfig,
+			ShutdownTimeout: DefaultShutdownTimeout,
+		},
 	}
 }
 
 func (s *Server) RunCleanupLoop() {
 	for code := range s.cleanup {
 		s.mux.Lock()
+		if session, ok := s.Sessions[code]; ok {
+			s.ended = append(s.ended, session)
+		}
+		connected := s.ended[:0]
+		for _, session := range s.ended {
+			if session.connected() {
+				connected = append(connected, session)
+			}
+		}
+		clear(s.ended[len(connected):])
+		s.ended = connected
 		delete(s.Sessions, code)
 		log.Printf("Cleaned up and removed session %s.", code)
 		s.mux.Unlock()
@@ -128,68 +185,128 @@ func (s *Server) handleWebSocketConnections(w http.ResponseWriter, r *http.Reque
 		log.Printf("websocket upgrade error: %v", err)
 		return
 	}
+	keepAlive(ws, s.Config.Conn)
+	client := newClient(ws, s.Config.Conn)
 	var msg InitialMessage
 	if err := ws.ReadJSON(&msg); err != nil {
 		log.Printf("error reading initial message: %v", err)
-		ws.Close()
+		client.Close()
 		return
 	}
 	s.mux.Lock()
+	if s.closing {
+		client.Send(ServerResponse{Type: "error", Message: "Server is shutting down."})
+		s.mux.Unlock()
+		client.CloseWhenSent()
+		return
+	}
+	if (msg.Type == "create" || msg.Type == "join") && !game.ValidClass(msg.Class) {
+		client.Send(ServerResponse{Type: "error", Message: "Unknown class."})
+		s.mux.Unlock()
+		client.CloseWhenSent()
+		return
+	}
 	var session *Session
 	var ok bool
 	switch msg.Type {
 	case "create":
 		code := strings.ToUpper(msg.Code)
 		if _, exists := s.Sessions[code]; exists {
-			ws.WriteJSON(ServerResponse{Type: "error", Message: "Room code is already taken."})
+			client.Send(ServerResponse{Type: "error", Message: "Room code is already taken."})
+			s.mux.Unlock()
+			client.CloseWhenSent()
+			return
+		}
+		seed := msg.Seed
+		if seed == 0 {
+			seed = generateSeed()
+		}
+		if msg.Depth < 0 || msg.Depth > maxRoomDepth {
+			client.Send(ServerResponse{Type: "error", Message: "Invalid dungeon depth."})
 			s.mux.Unlock()
-			ws.Close()
+			client.CloseWhenSent()
+			return
+		}
+		cfg := game.Config{Seed: seed, MaxDepth: msg.Depth, Generator: msg.Generator, SharedExploration: msg.SharedMap, SharedXP: msg.SharedXP}
+		session, err = NewSession(code, cfg, s.Config, s.cleanup)
+		if err != nil {
+			message := "Unknown dungeon generator."
+			if !errors.Is(err, game.ErrUnknownGenerator) {
+				log.Printf("[ERROR] creating session %s: %v", code, err)
+				message = "Could not build the dungeon."
+			}
+			client.Send(ServerResponse{Type: "error", Message: message})
+			s.mux.Unlock()
+			client.CloseWhenSent()
 			return
 		}
-		session = NewSession(code, s.cleanup)
 		s.Sessions[code] = session
 		go session.RunLoop()
-		log.Printf("New session created with code: %s", code)
+		metrics := session.GameState.Metrics
+		log.Printf("New session created with code: %s (seed %d, generator %s)", code, seed, session.GameState.Config.Generator)
+		log.Printf("Session %s floor 1: floor ratio %.2f, exit path %d, %d choke points", code, metrics.FloorRatio, metrics.ExitPathLength, len(metrics.ChokePoints))
 	case "join":
 		code := strings.ToUpper(msg.Code)
 		session, ok = s.Sessions[code]
 		if !ok || session.IsOver {
-			ws.WriteJSON(ServerResponse{Type: "error", Message: "Room not found or has ended."})
+			client.Send(ServerResponse{Type: "error", Message: "Room not found or has ended."})
 			s.mux.Unlock()
-			ws.Close()
+			client.CloseWhenSent()
 			return
 		}
-		if len(session.Clients) >= 5 {
-			ws.WriteJSON(ServerResponse{Type: "error", Message: "Room is full."})
+		if session.PlayerCount() >= 5 {
+			client.Send(ServerResponse{Type: "error", Message: "Room is full."})
+			s.mux.Unlock()
+			client.CloseWhenSent()
+			return
+		}
+	case "resume":
+		code := strings.ToUpper(msg.Code)
+		session, ok = s.Sessions[code]
+		if !ok || session.IsOver {
+			client.Send(ServerResponse{Type: "error", Message: "Room not found or has ended."})
 			s.mux.Unlock()
-			ws.Close()
+			client.CloseWhenSent()
 			return
 		}
+		s.mux.Unlock()
+		if err := session.Resume(client, msg.Token); err != nil {
+			message := "Could not resume."
+			switch {
+			case errors.Is(err, errUnknownToken):
+				message = "Reconnect token not recognised or expired."
+			case errors.Is(err, errStillConnected):
+				message = "Player is still connected; try again shortly."
+			case errors.Is(err, errPlayerNotInGame):
+				message = "Player is no longer in this game."
+			}
+			client.Send(ServerResponse{Type: "error", Message: message})
+			client.CloseWhenSent()
+		}
+		return
 	default:
-		ws.WriteJSON(ServerResponse{Type: "error", Message: "Invalid request."})
+		client.Send(ServerResponse{Type: "error", Message: "Invalid request."})
 		s.mux.Unlock()
-		ws.Close()
+		client.CloseWhenSent()
 		return
 	}
 	s.mux.Unlock()
-	session.AddClient(ws)
+	session.AddClient(client, msg.Class)
 }
 
-func (s *Session) AddClient(conn *websocket.Conn) {
+func (s *Session) AddClient(client *Client, class string) {
 	playerID := uuid.New().String()
 	s.mux.Lock()
-	startPos := s.GameState.GetRandomSpawnPoint()
-	newPlayer := game.NewPlayer(playerID, startPos)
-	s.GameState.Players[playerID] = newPlayer
-	client := &Client{
-		Conn:       conn,
-		PlayerID:   playerID,
-		CmdChannel: s.CommandStream,
-	}
+	s.recordJoin(playerID, class)
+	s.GameState.AddPlayer(playerID, class)
+	client.attach(playerID, s.CommandStream)
+	token := s.issueToken(playerID)
+	// The welcome must be queued before the client is visible to
+	// BroadcastState, or a state frame could reach it first.
+	client.Send(ServerResponse{Type: "welcome", ID: playerID, Code: s.Code, Seed: s.GameState.Config.Seed, Token: token})
 	s.Clients[playerID] = client
 	s.mux.Unlock()
-	conn.WriteJSON(ServerResponse{Type: "welcome", ID: playerID, Code: s.Code})
-	log.Printf("Player %s (%s) has joined session %s.", playerID, conn.RemoteAddr(), s.Code)
+	log.Printf("Player %s (%s) has joined session %s.", playerID, client.Conn.RemoteAddr(), s.Code)
 	go client.Listen(s)
 	s.BroadcastState()
 }
@@ -199,10 +316,11 @@ func (s *Session) RemoveClient(playerID string, shouldCloseConn bool) {
 	defer s.mux.Unlock()
 	if client, ok := s.Clients[playerID]; ok {
 		if shouldCloseConn {
-			client.Conn.Close()
+			client.Close()
 		}
 		delete(s.Clients, playerID)
-		delete(s.GameState.Players, playerID)
+		s.record(game.EventRemove, playerID, nil)
+		s.GameState.RemovePlayer(playerID)
 		log.Printf("Player %s removed from session %s.", playerID, s.Code)
 	}
 }
@@ -211,49 +329,41 @@ func (s *Session) BroadcastState() {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 	for _, client := range s.Clients {
-		player, ok := s.GameState.Players[client.PlayerID]
-		if !ok {
-			continue
-		}
-		teamworkRadius := 8
-		teamworkBonusPerAlly := 2
-		nearbyAllyCount := 0
-		if player.Status == "playing" {
-			for otherPlayerID, otherPlayer := range s.GameState.Players {
-				if player.ID != otherPlayerID && otherPlayer.Status == "playing" {
-					if game.Distance(player.Position, otherPlayer.Position) <= teamworkRadius {
-						nearbyAllyCount++
-					}
-				}
-			}
-		}
-		effectiveVision := player.VisionRadius + (nearbyAllyCount * teamworkBonusPerAlly)
-		visibleTilesMap := game.CalculateVisibility(player.Position, effectiveVision)
-		visibleForJSON := []dungeon.Point{}
-		for p := range visibleTilesMap {
-			visibleForJSON = append(visibleForJSON, p)
-		}
-		itemsForJSON := []game.ItemOnGroundJSON{}
-		for pos, item := range s.GameState.ItemsOnGround {
-			itemsForJSON = append(itemsForJSON, game.ItemOnGroundJSON{Position: pos, Item: item})
-		}
-		highlighted := []dungeon.Point{}
-		if player.Status == "targeting" && player.Target != nil {
-			highlighted = game.GetLineOfSightPath(player.Position, *player.Target)
+		s.sendState(client)
+	}
+}
+
+// Resync sends a player a full keyframe, for clients that think they have
+// missed an update.
+func (s *Session) Resync(playerID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if client, ok := s.Clients[playerID]; ok {
+		client.view.Reset()
+		s.sendState(client)
+	}
+}
+
+// sendState sends a client what changed since its last update, or a full
+// keyframe when one is due. The caller must hold s.mux.
+func (s *Session) sendState(client *Client) {
+	stateForJSON, ok := s.GameState.ViewFor(client.PlayerID)
+	if !ok {
+		return
+	}
+	// A frame dropped under backpressure leaves the client's copy behind,
+	// so follow it with a keyframe rather than a delta.
+	for attempt := 0; attempt < 2; attempt++ {
+		if client.NeedsKeyframe() {
+			client.view.Reset()
 		}
-		stateForJSON := game.GameStateForJSON{
-			Dungeon:          s.GameState.Dungeon,
-			Monsters:         s.GameState.Monsters,
-			Players:          s.GameState.Players,
-			ExitPos:          s.GameState.ExitPos,
-			Log:              s.GameState.Log,
-			ItemsOnGround:    itemsForJSON,
-			HighlightedTiles: highlighted,
-			VisibleTiles:     visibleForJSON,
+		msgType, payload, err := client.view.Update(stateForJSON)
+		if err != nil {
+			log.Printf("[ERROR] encoding state for %s: %v", client.PlayerID[0:4], err)
+			return
 		}
-		stateMsg := map[string]interface{}{"type": "state", "data": stateForJSON}
-		if err := client.Conn.WriteJSON(stateMsg); err != nil {
-			log.Printf("[ERROR] broadcast error to %s: %v", client.PlayerID[0:4], err)
+		if client.SendState(map[string]interface{}{"type": msgType, "data": payload}) {
+			return
 		}
 	}
 }
@@ -261,7 +371,7 @@ func (s *Session) BroadcastState() {
 func (c *Client) Listen(s *Session) {
 	defer func() {
 		log.Printf("[DEBUG] Client %s Listen() ending, sending quit", c.PlayerID[0:4])
-		s.CommandStream <- game.ClientCommand{PlayerID: c.PlayerID, Command: "quit"}
+		s.CommandStream <- game.ClientCommand{PlayerID: c.PlayerID, Command: game.Command{Type: game.CmdQuit}}
 	}()
 	for {
 		_, p, err := c.Conn.ReadMessage()
@@ -269,43 +379,61 @@ func (c *Client) Listen(s *Session) {
 			log.Printf("[DEBUG] ReadMessage error for %s: %v", c.PlayerID[0:4], err)
 			break
 		}
-		s.CommandStream <- game.ClientCommand{PlayerID: c.PlayerID, Command: string(p)}
+		c.Conn.SetReadDeadline(time.Now().Add(c.cfg.PongWait))
+		cmd, err := game.ParseCommand(p)
+		if err != nil {
+			s.sendError(c, err.Error())
+			continue
+		}
+		s.CommandStream <- game.ClientCommand{PlayerID: c.PlayerID, Command: cmd}
 	}
 }
 
+// sendError tells a single client that its last command was rejected.
+func (s *Session) sendError(c *Client, message string) {
+	c.Send(ServerResponse{Type: "error", Message: message})
+}
+
 func (s *Session) RunLoop() {
+    result := "abandoned"
     defer func() {
         log.Printf("Session %s RunLoop ended.", s.Code)
+        s.endReplay(result)
+        close(s.done)
+        s.stopHolds()
         s.cleanup <- s.Code
     }()
 
     for cmd := range s.CommandStream {
-        if cmd.Command == "quit" {
-            s.RemoveClient(cmd.PlayerID, true)
-            if len(s.Clients) == 0 {
+        if cmd.Command.Type == game.CmdQuit || cmd.Command.Type == game.CmdExpire {
+            if cmd.Command.Type == game.CmdQuit {
+                s.HoldPlayer(cmd.PlayerID)
+            } else {
+                s.ExpireHold(cmd.PlayerID)
+            }
+            if s.isEmpty() {
                 log.Printf("Session %s is empty, closing.", s.Code)
                 s.mux.Lock()
                 s.IsOver = true
                 s.mux.Unlock()
                 return
             }
+        } else if cmd.Command.Type == game.CmdShutdown {
+            result = "shutdown"
+            s.shutdown()
+            return
+        } else if cmd.Command.Type == game.CmdResync {
+            s.Resync(cmd.PlayerID)
+            continue
         } else {
-            playersWhoWon, endTurnEarly := game.ProcessPlayerCommand(cmd.PlayerID, cmd.Command, &s.GameState)
-            if !endTurnEarly {
-                game.UpdateMonsters(&s.GameState)
-            }
-            allPlayersDefeated := len(s.GameState.Players) > 0
-            for _, p := range s.GameState.Players {
-                if p.Status == "playing" || p.Status == "targeting" {
-                    allPlayersDefeated = false
-                    break
-                }
-            }
+            s.mux.Lock()
+            s.record(game.EventCommand, cmd.PlayerID, &cmd.Command)
+            playersWhoWon := s.GameState.Step(cmd)
+            allPlayersDefeated := s.GameState.AllDefeated()
+            s.mux.Unlock()
             if len(playersWhoWon) > 0 || allPlayersDefeated {
-                var result string
                 if allPlayersDefeated {
                     result = "defeat"
-                    s.GameState.AddMessage("All players have been defeated! The dungeon claims its victims.")
                 } else {
                     result = "victory"
                 }
@@ -325,17 +453,75 @@ func (s *Session) RunLoop() {
 }
 
 func main() {
+	contentDir := os.Getenv("CONTENT_DIR")
+	if contentDir == "" {
+		contentDir = "./content"
+	}
+	files, err := game.LoadContentDir(contentDir)
+	if err != nil {
+		log.Fatalf("loading content packs from %s: %v", contentDir, err)
+	}
+	for _, file := range files {
+		log.Printf("Loaded content pack %s", file)
+	}
 	server := NewServer()
+	if err := server.Config.loadEnv(); err != nil {
+		log.Fatal(err)
+	}
+	if replayDir := os.Getenv("REPLAY_DIR"); replayDir != "" {
+		if err := os.MkdirAll(replayDir, 0o755); err != nil {
+			log.Fatalf("creating replay directory %s: %v", replayDir, err)
+		}
+		server.Config.ReplayDir = replayDir
+	}
+	if snapshotDir := os.Getenv("SNAPSHOT_DIR"); snapshotDir != "" {
+		if err := os.MkdirAll(snapshotDir, 0o755); err != nil {
+			log.Fatalf("creating snapshot directory %s: %v", snapshotDir, err)
+		}
+		server.Config.Snapshot = func(s *Session) error {
+			return s.SaveFile(snapshotDir)
+		}
+		codes, err := server.LoadSnapshots(snapshotDir)
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+		}
+		for _, code := range codes {
+			log.Printf("Restored session %s from %s; waiting for players to resume.", code, snapshotDir)
+		}
+	}
 	go server.RunCleanupLoop()
-	http.Handle("/", http.FileServer(http.Dir("./static")))
-	http.HandleFunc("/ws", server.handleWebSocketConnections)
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir("./static")))
+	mux.HandleFunc("/ws", server.handleWebSocketConnections)
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
-	log.Printf("Game server starting on port %s", port)
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
+	httpServer := &http.Server{Addr: ":" + port, Handler: mux}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Game server starting on port %s", port)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
 		log.Fatal("ListenAndServe:", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Printf("Shutting down; waiting up to %s for sessions to close.", server.Config.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), server.Config.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("[ERROR] HTTP shutdown: %v", err)
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("[ERROR] sessions did not close in time: %v", err)
 	}
+	log.Printf("Server stopped.")
 }
 
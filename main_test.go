@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"dunExpo/client"
+	"dunExpo/game"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestBotsPlayFullGames starts a server on a private port and plays a room of
+// bots, cycling through the built-in strategies and the classes, to the end
+// of the game. The harness checks its invariants on every update.
+func TestBotsPlayFullGames(t *testing.T) {
+	seeds := []int64{1, 2, 3, 4, 5}
+	if testing.Short() {
+		seeds = seeds[:1]
+	}
+	for _, seed := range seeds {
+		t.Run(fmt.Sprintf("seed%d", seed), func(t *testing.T) {
+			report, err := playBots(t, 4, seed, 2000)
+			t.Log("\n" + report.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range report.Violations() {
+				t.Error(v)
+			}
+		})
+	}
+}
+
+func playBots(t *testing.T, n int, seed int64, commands int) (client.Report, error) {
+	server := NewServer()
+	go server.RunCleanupLoop()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", server.handleWebSocketConnections)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	strategies := client.Strategies()
+	harness := client.Harness{
+		URL:         "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws",
+		Room:        client.Room{Seed: seed},
+		Delay:       time.Millisecond,
+		MaxCommands: commands,
+		ResyncEvery: 25,
+	}
+	classes := make([]string, 0, len(game.Classes))
+	for key := range game.Classes {
+		classes = append(classes, key)
+	}
+	sort.Strings(classes)
+	for i := 0; i < n; i++ {
+		harness.Strategies = append(harness.Strategies, strategies[i%len(strategies)])
+		if len(classes) > 0 {
+			harness.Classes = append(harness.Classes, classes[i%len(classes)])
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	return harness.Run(ctx)
+}
+
+// A rejected command gets an error reply naming the problem, and the player
+// stays connected.
+func TestInvalidCommandsGetErrorReplies(t *testing.T) {
+	server := NewServer()
+	go server.RunCleanupLoop()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", server.handleWebSocketConnections)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, "ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	if err := conn.WriteJSON(client.InitialMessage{Type: "create", Code: "BADC", Seed: 1}); err != nil {
+		t.Fatal(err)
+	}
+	var welcome ServerResponse
+	if err := conn.ReadJSON(&welcome); err != nil || welcome.Type != "welcome" {
+		t.Fatalf("got %+v, %v, want a welcome", welcome, err)
+	}
+
+	tests := []struct {
+		frame, want string
+	}{
+		{`{"type":"move","dir":"north"}`, "unsupported protocol version 0, expected 1"},
+		{`{"v":1,"type":"dance"}`, `unknown command type "dance"`},
+		{`{"v":1,"type":"move","dir":"up"}`, `move: dir must be north, south, east or west, not "up"`},
+		{`{"v":1,"type":"attack"}`, "attack: target is required"},
+		{`{"v":1,"type":"use"}`, "use: item is required"},
+	}
+	for _, tt := range tests {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(tt.frame)); err != nil {
+			t.Fatal(err)
+		}
+		var reply ServerResponse
+		for reply.Type != "error" {
+			reply = ServerResponse{}
+			if err := conn.ReadJSON(&reply); err != nil {
+				t.Fatalf("%s: %v", tt.frame, err)
+			}
+		}
+		if reply.Message != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.frame, reply.Message, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"dunExpo/game"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultReconnectGrace is how long a disconnected player's character is held
+// for them to resume before it is removed from the game.
+const DefaultReconnectGrace = 60 * time.Second
+
+// Errors returned by Resume. The handler turns them into the message the
+// client is sent.
+var (
+	errUnknownToken    = errors.New("reconnect token not recognised or expired")
+	errStillConnected  = errors.New("player is still connected")
+	errPlayerNotInGame = errors.New("player is no longer in the game")
+)
+
+// issueToken creates the reconnect token a player can later resume with.
+// The caller must hold s.mux.
+func (s *Session) issueToken(playerID string) string {
+	token := uuid.New().String()
+	s.tokens[token] = playerID
+	return token
+}
+
+// HoldPlayer detaches a player whose connection dropped but keeps their
+// character in the game for the grace period. Held players are "away":
+// they cannot act and monsters ignore them.
+func (s *Session) HoldPlayer(playerID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	client, ok := s.Clients[playerID]
+	if !ok {
+		return
+	}
+	client.Close()
+	delete(s.Clients, playerID)
+	if s.hold(playerID) {
+		log.Printf("Player %s disconnected from session %s; holding for %s.", playerID, s.Code, s.settings.ReconnectGrace)
+	}
+}
+
+// hold marks a player away and starts their grace period. The caller must
+// hold s.mux.
+func (s *Session) hold(playerID string) bool {
+	if _, ok := s.GameState.Players[playerID]; !ok {
+		return false
+	}
+	s.record(game.EventLeave, playerID, nil)
+	s.GameState.SetAway(playerID)
+	s.held[playerID] = time.AfterFunc(s.settings.ReconnectGrace, func() {
+		select {
+		case s.CommandStream <- game.ClientCommand{PlayerID: playerID, Command: game.Command{Type: game.CmdExpire}}:
+		case <-s.done:
+		}
+	})
+	return true
+}
+
+// ExpireHold removes a held player whose grace period ran out without them
+// coming back.
+func (s *Session) ExpireHold(playerID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, back := s.Clients[playerID]; back {
+		return
+	}
+	if _, ok := s.held[playerID]; !ok {
+		return
+	}
+	delete(s.held, playerID)
+	s.record(game.EventRemove, playerID, nil)
+	s.GameState.RemovePlayer(playerID)
+	for token, id := range s.tokens {
+		if id == playerID {
+			delete(s.tokens, token)
+		}
+	}
+	log.Printf("Player %s did not reconnect and was removed from session %s.", playerID, s.Code)
+}
+
+// Resume reattaches a new connection to the player the token was issued to.
+func (s *Session) Resume(client *Client, token string) error {
+	s.mux.Lock()
+	playerID, ok := s.tokens[token]
+	if !ok {
+		s.mux.Unlock()
+		return errUnknownToken
+	}
+	if _, connected := s.Clients[playerID]; connected {
+		s.mux.Unlock()
+		return errStillConnected
+	}
+	if _, ok := s.GameState.Players[playerID]; !ok {
+		s.mux.Unlock()
+		return errPlayerNotInGame
+	}
+	if timer, ok := s.held[playerID]; ok {
+		timer.Stop()
+		delete(s.held, playerID)
+	}
+	s.record(game.EventReturn, playerID, nil)
+	s.GameState.SetBack(playerID)
+	client.attach(playerID, s.CommandStream)
+	// As in AddClient, the welcome goes out before any state frame.
+	client.Send(ServerResponse{Type: "welcome", ID: playerID, Code: s.Code, Seed: s.GameState.Config.Seed, Token: token})
+	s.Clients[playerID] = client
+	s.mux.Unlock()
+	log.Printf("Player %s (%s) resumed in session %s.", playerID, client.Conn.RemoteAddr(), s.Code)
+	go client.Listen(s)
+	s.BroadcastState()
+	return nil
+}
+
+// isEmpty reports whether nobody is connected or being held.
+func (s *Session) isEmpty() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.Clients) == 0 && len(s.held) == 0
+}
+
+// PlayerCount counts connected and held players, who all take up a slot.
+func (s *Session) PlayerCount() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.GameState.Players)
+}
+
+// stopHolds cancels every pending grace period once the session is over.
+func (s *Session) stopHolds() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for id, timer := range s.held {
+		timer.Stop()
+		delete(s.held, id)
+	}
+}
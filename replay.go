@@ -0,0 +1,78 @@
+package main
+
+import (
+	"dunExpo/game"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// startReplay opens <dir>/<code>-<time>.replay and writes its header. A
+// resumed session's replay starts from its current state rather than from
+// the seed. A session that cannot record still runs, just without a replay.
+func (s *Session) startReplay(dir string, resumed bool) {
+	name := fmt.Sprintf("%s-%s.replay", s.Code, time.Now().UTC().Format("20060102T150405"))
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		log.Printf("[ERROR] session %s will not be recorded: %v", s.Code, err)
+		return
+	}
+	var w *game.ReplayWriter
+	if resumed {
+		w, err = game.ResumeReplayWriter(f, &s.GameState)
+	} else {
+		w, err = game.NewReplayWriter(f, s.GameState.Config)
+	}
+	if err != nil {
+		log.Printf("[ERROR] session %s will not be recorded: %v", s.Code, err)
+		f.Close()
+		return
+	}
+	s.replay, s.replayFile = w, f
+	log.Printf("Recording session %s to %s", s.Code, f.Name())
+}
+
+// record appends an event to the session's replay, if it keeps one. It must
+// be called, with s.mux held, just before the matching GameState change.
+func (s *Session) record(kind, playerID string, cmd *game.Command) {
+	if s.replay == nil {
+		return
+	}
+	if err := s.replay.Record(kind, playerID, cmd); err != nil {
+		log.Printf("[ERROR] recording session %s stopped: %v", s.Code, err)
+		s.closeReplay()
+	}
+}
+
+// recordJoin is record for a player joining as class.
+func (s *Session) recordJoin(playerID, class string) {
+	if s.replay == nil {
+		return
+	}
+	if err := s.replay.RecordJoin(playerID, class); err != nil {
+		log.Printf("[ERROR] recording session %s stopped: %v", s.Code, err)
+		s.closeReplay()
+	}
+}
+
+// endReplay writes the final state hash and closes the replay.
+func (s *Session) endReplay(result string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.replay == nil {
+		return
+	}
+	if err := s.replay.End(&s.GameState, result); err != nil {
+		log.Printf("[ERROR] finishing replay of session %s: %v", s.Code, err)
+	}
+	s.closeReplay()
+}
+
+func (s *Session) closeReplay() {
+	if err := s.replayFile.Close(); err != nil {
+		log.Printf("[ERROR] closing replay of session %s: %v", s.Code, err)
+	}
+	s.replay, s.replayFile = nil, nil
+}
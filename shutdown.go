@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"dunExpo/game"
+	"log"
+	"sort"
+	"time"
+)
+
+// DefaultShutdownTimeout bounds how long a graceful shutdown may take before
+// the remaining connections are dropped.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// Notices sent to every connected client before the server stops, depending
+// on whether their session was saved.
+const (
+	shutdownNotice = "The server is shutting down. This run has ended."
+	snapshotNotice = "The server is restarting. This run has been saved; resume it with your token once the server is back."
+)
+
+// Shutdown stops new rooms, ends every running session and waits for their
+// clients to be notified and disconnected, or for ctx to expire.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mux.Lock()
+	s.closing = true
+	codes := make([]string, 0, len(s.Sessions))
+	for code := range s.Sessions {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	sessions := make([]*Session, 0, len(codes)+len(s.ended))
+	for _, code := range codes {
+		sessions = append(sessions, s.Sessions[code])
+	}
+	sessions = append(sessions, s.ended...)
+	s.mux.Unlock()
+
+	for _, session := range sessions {
+		select {
+		case session.CommandStream <- game.ClientCommand{Command: game.Command{Type: game.CmdShutdown}}:
+		case <-session.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	for _, session := range sessions {
+		select {
+		case <-session.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		// A session whose game was over before the shutdown has no loop
+		// left to run it, but its players may still be connected.
+		session.notifyShutdown(shutdownNotice)
+		for _, client := range session.closingClients {
+			select {
+			case <-client.quit:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// shutdown runs on the session loop: it saves the session if the server
+// keeps snapshots, tells every client and lets their queues drain before the
+// connections close.
+func (s *Session) shutdown() {
+	notice := shutdownNotice
+	if s.settings.Snapshot != nil {
+		if err := s.settings.Snapshot(s); err != nil {
+			log.Printf("[ERROR] snapshot of session %s failed: %v", s.Code, err)
+		} else {
+			log.Printf("Saved a snapshot of session %s.", s.Code)
+			notice = snapshotNotice
+		}
+	}
+	s.notifyShutdown(notice)
+}
+
+// notifyShutdown tells every client the server is stopping and closes their
+// connections once the notice is written. Only the first call has any
+// effect.
+func (s *Session) notifyShutdown(notice string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.notified {
+		return
+	}
+	s.notified = true
+	s.IsOver = true
+	for _, id := range sortedClientIDs(s.Clients) {
+		client := s.Clients[id]
+		client.Send(ServerResponse{Type: "shutdown", Message: notice})
+		client.CloseWhenSent()
+		s.closingClients = append(s.closingClients, client)
+	}
+}
+
+// connected reports whether any of the session's clients is still connected.
+func (s *Session) connected() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, client := range s.Clients {
+		if !client.closed() {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedClientIDs(clients map[string]*Client) []string {
+	ids := make([]string, 0, len(clients))
+	for id := range clients {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"dunExpo/client"
+	"dunExpo/game"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Players whose game is already over are still connected when the server
+// stops, and get the shutdown notice like everyone else.
+func TestShutdownNotifiesFinishedGames(t *testing.T) {
+	server := NewServer()
+	go server.RunCleanupLoop()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", server.handleWebSocketConnections)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := client.Dial(ctx, "ws"+strings.TrimPrefix(ts.URL, "http")+"/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Create(client.Room{Code: "DONE", Seed: 1}); err != nil {
+		t.Fatal(err)
+	}
+	server.mux.Lock()
+	session := server.Sessions["DONE"]
+	server.mux.Unlock()
+	session.mux.Lock()
+	for _, p := range session.GameState.Players {
+		p.HP, p.Status = 0, "defeated"
+	}
+	session.mux.Unlock()
+	if err := c.Send(game.Command{Type: game.CmdPickup}); err != nil {
+		t.Fatal(err)
+	}
+	next := func() client.Message {
+		t.Helper()
+		msg, err := c.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	for next().Type != "gameOver" {
+	}
+	select {
+	case <-session.done:
+	case <-ctx.Done():
+		t.Fatal("session did not end")
+	}
+	// Let the cleanup loop drop the session from the room list.
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		server.mux.Lock()
+		_, listed := server.Sessions["DONE"]
+		server.mux.Unlock()
+		if !listed {
+			break
+		}
+	}
+
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Without a notice the connection would stay open and Next would block.
+	time.AfterFunc(2*time.Second, func() { c.Close() })
+	msg := next()
+	for msg.Type == "state" || msg.Type == "delta" {
+		msg = next()
+	}
+	if msg.Type != "shutdown" || msg.Response.Message != shutdownNotice {
+		t.Errorf("got %q %q, want the shutdown notice", msg.Type, msg.Response.Message)
+	}
+}
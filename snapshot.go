@@ -0,0 +1,153 @@
+package main
+
+import (
+	"dunExpo/game"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SessionSaveVersion is the current session save format.
+const SessionSaveVersion = 1
+
+// SavedSession is everything needed to bring a room back on another server
+// or after a restart: the game itself plus the room code and the reconnect
+// tokens its players resume with.
+type SavedSession struct {
+	Version int
+	Code    string
+	SavedAt time.Time
+	Tokens  map[string]string
+	Game    game.SavedGame
+}
+
+// Save writes the session in the versioned save format.
+func (s *Session) Save(w io.Writer) error {
+	s.mux.Lock()
+	saved := SavedSession{
+		Version: SessionSaveVersion,
+		Code:    s.Code,
+		SavedAt: time.Now().UTC(),
+		Tokens:  make(map[string]string, len(s.tokens)),
+		Game:    s.GameState.Save(),
+	}
+	for token, id := range s.tokens {
+		saved.Tokens[token] = id
+	}
+	s.mux.Unlock()
+	return json.NewEncoder(w).Encode(saved)
+}
+
+// LoadSession rebuilds a session from a save. Nobody is connected yet, so
+// every player is held for the reconnect grace period, waiting to resume
+// with the token they already have. With replays enabled, a new replay is
+// started from the loaded game. The caller starts RunLoop.
+func LoadSession(r io.Reader, settings ServerConfig, cleanup chan<- string) (*Session, error) {
+	var saved SavedSession
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return nil, fmt.Errorf("decoding session: %w", err)
+	}
+	if saved.Version != SessionSaveVersion {
+		return nil, fmt.Errorf("unsupported session save version %d (want %d)", saved.Version, SessionSaveVersion)
+	}
+	gs, err := game.LoadGame(saved.Game)
+	if err != nil {
+		return nil, fmt.Errorf("session %s: %w", saved.Code, err)
+	}
+	s := &Session{
+		Code:          saved.Code,
+		GameState:     *gs,
+		Clients:       make(map[string]*Client),
+		CommandStream: make(chan game.ClientCommand, 100),
+		cleanup:       cleanup,
+		tokens:        make(map[string]string, len(saved.Tokens)),
+		held:          make(map[string]*time.Timer),
+		settings:      settings,
+		done:          make(chan struct{}),
+	}
+	for token, id := range saved.Tokens {
+		if _, ok := gs.Players[id]; ok {
+			s.tokens[token] = id
+		}
+	}
+	s.mux.Lock()
+	if settings.ReplayDir != "" {
+		s.startReplay(settings.ReplayDir, true)
+	}
+	for _, id := range s.GameState.PlayerIDs() {
+		s.hold(id)
+	}
+	s.mux.Unlock()
+	return s, nil
+}
+
+// SaveFile writes the session to <dir>/<code>.json, replacing any earlier
+// snapshot only once the new one is complete.
+func (s *Session) SaveFile(dir string) error {
+	tmp, err := os.CreateTemp(dir, s.Code+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := s.Save(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, s.Code+".json"))
+}
+
+// LoadSnapshots restores every session saved in dir and removes the files it
+// loaded. Snapshots that cannot be loaded are left in place and reported.
+func (s *Server) LoadSnapshots(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var loaded []string
+	var problems []string
+	for _, path := range paths {
+		session, err := s.loadSnapshot(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", filepath.Base(path), err))
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("[WARN] could not remove loaded snapshot %s: %v", path, err)
+		}
+		loaded = append(loaded, session.Code)
+	}
+	if len(problems) > 0 {
+		return loaded, fmt.Errorf("loading snapshots: %s", strings.Join(problems, "; "))
+	}
+	return loaded, nil
+}
+
+func (s *Server) loadSnapshot(path string) (*Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	session, err := LoadSession(f, s.Config, s.cleanup)
+	if err != nil {
+		return nil, err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, exists := s.Sessions[session.Code]; exists {
+		return nil, fmt.Errorf("room %s is already running", session.Code)
+	}
+	s.Sessions[session.Code] = session
+	go session.RunLoop()
+	return session, nil
+}